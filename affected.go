@@ -0,0 +1,40 @@
+package main
+
+import (
+	"path/filepath"
+	"sort"
+)
+
+// GetAffectedWorkspaces maps the files given to the workspaces containing
+// them and returns those workspaces as well as all workspaces depending on
+// them, sorted by their root. Files are expected to be absolute paths without
+// symlinks as reported by git, files outside of any workspace are ignored.
+func GetAffectedWorkspaces(workspaces map[string]*Workspace, files []string) ([]*Workspace, error) {
+	affected := []*Workspace{}
+
+	roots := map[string]*Workspace{}
+	for _, ws := range workspaces {
+		abs, err := resolvePath(ws.Root)
+		if err != nil {
+			return affected, err
+		}
+		roots[abs] = ws
+	}
+
+	visited := map[*Workspace]bool{}
+	for _, file := range files {
+		// find the closest workspace containing the file
+		for dir := filepath.Dir(file); ; dir = filepath.Dir(dir) {
+			if ws, ok := roots[dir]; ok {
+				affected = append(affected, downstreamWorkspaces(ws, visited)...)
+				break
+			}
+			if dir == filepath.Dir(dir) {
+				break
+			}
+		}
+	}
+
+	sort.Slice(affected, func(i, j int) bool { return affected[i].Root < affected[j].Root })
+	return affected, nil
+}
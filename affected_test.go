@@ -0,0 +1,137 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// affectedFixture contains a top-level workspace, a chain net <- api <- web
+// and api-v2 whose root starts with the root of api.
+var affectedFixture = map[string]string{
+	"main.tf":             tfBackend("top") + tfOutput("account", `"123"`),
+	"net/main.tf":         tfBackend("net") + tfOutput("vpc_id", `"vpc"`),
+	"apps/api/main.tf":    tfBackend("api") + tfRemoteState("net", "net") + tfOutput("url", "data.terraform_remote_state.net.outputs.vpc_id"),
+	"apps/api-v2/main.tf": tfBackend("api-v2") + tfOutput("url", `"v2"`),
+	"apps/web/main.tf":    tfBackend("web") + tfRemoteState("api", "api") + tfOutput("host", "data.terraform_remote_state.api.outputs.url"),
+	"apps/README.md":      "apps\n",
+}
+
+func TestAffectedSince(t *testing.T) {
+	dir, cleanup := gitFixture(t, affectedFixture)
+	defer cleanup()
+	defer chdir(t, dir)()
+
+	tests := []struct {
+		name     string
+		changes  map[string]string
+		moves    map[string]string
+		affected []string
+		plan     []string
+	}{
+		{
+			name:     "nothing changed",
+			changes:  map[string]string{},
+			affected: []string{},
+			plan:     []string{},
+		},
+		{
+			name:     "producer changed",
+			changes:  map[string]string{"apps/api/main.tf": affectedFixture["apps/api/main.tf"] + "# changed\n"},
+			affected: []string{"apps/api/", "apps/web/"},
+			plan:     []string{"apps/api/", "apps/web/"},
+		},
+		{
+			name:     "top-level workspace changed",
+			changes:  map[string]string{"main.tf": affectedFixture["main.tf"] + "# changed\n"},
+			affected: []string{""},
+			plan:     []string{""},
+		},
+		{
+			name:     "untracked manual added",
+			changes:  map[string]string{"apps/api-v2/PreManual.md": "check the DNS records\n"},
+			affected: []string{"apps/api-v2/"},
+			plan:     []string{"apps/api-v2/"},
+		},
+		{
+			name:     "file below the top-level workspace changed",
+			changes:  map[string]string{"apps/README.md": "changed\n"},
+			affected: []string{""},
+			plan:     []string{""},
+		},
+		{
+			name:     "file moved between workspaces",
+			changes:  map[string]string{},
+			moves:    map[string]string{"apps/README.md": "net/README.md"},
+			affected: []string{"", "apps/api/", "apps/web/", "net/"},
+			plan:     []string{" net/", "apps/api/", "apps/web/"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeFixture(t, dir, tt.changes)
+			defer runGit(t, dir, "clean", "-q", "-f", "-d")
+			defer runGit(t, dir, "reset", "-q", "--hard")
+			for from, to := range tt.moves {
+				runGit(t, dir, "mv", from, to)
+			}
+
+			files, err := gitChangedFiles(".", "HEAD")
+			if err != nil {
+				t.Fatal(err)
+			}
+			workspaces, err := GetWorkspaces(".", []string{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			affected, err := GetAffectedWorkspaces(workspaces, files)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := workspaceRoots(affected); !equalStrings(got, tt.affected) {
+				t.Errorf("affected workspaces are %q, want %q", got, tt.affected)
+			}
+			if len(affected) == 0 {
+				return
+			}
+
+			plan, err := BuildExecutionPlanFrom(workspaceSlice(workspaces), affected, func(string) {})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := planRoots(plan); !equalStrings(got, tt.plan) {
+				t.Errorf("plan is %q, want %q", got, tt.plan)
+			}
+		})
+	}
+}
+
+func TestAffectedSymlinkedCheckout(t *testing.T) {
+	dir, cleanup := gitFixture(t, affectedFixture)
+	defer cleanup()
+
+	link := dir + "-link"
+	if err := os.Symlink(dir, link); err != nil {
+		t.Skip(err)
+	}
+	defer os.Remove(link)
+	writeFixture(t, dir, map[string]string{"apps/api/main.tf": affectedFixture["apps/api/main.tf"] + "# changed\n"})
+
+	files, err := gitChangedFiles(link, "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	workspaces, err := GetWorkspaces(link+string(filepath.Separator), []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	affected, err := GetAffectedWorkspaces(workspaces, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(link, "apps", "api") + "/", filepath.Join(link, "apps", "web") + "/"}
+	if got := workspaceRoots(affected); !equalStrings(got, want) {
+		t.Errorf("affected workspaces are %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// ExportAtlantis writes an atlantis.yaml (repo config version 3) with one
+// project per workspace of the plan. The tier of a workspace is used as its
+// execution order group, the directories of local modules are added to the
+// patterns triggering autoplan.
+func ExportAtlantis(w io.Writer, plan [][]*Workspace) {
+	group := map[*Workspace]int{}
+	for tier, workspaces := range plan {
+		for _, ws := range workspaces {
+			group[ws] = tier
+		}
+	}
+
+	roots := []string{}
+	for _, ws := range flattenPlan(plan) {
+		roots = append(roots, ws.Root)
+	}
+	names := exportIDs(roots)
+
+	fmt.Fprintf(w, "# generated by solaris, do not edit\n")
+	fmt.Fprintf(w, "version: 3\n")
+	fmt.Fprintf(w, "parallel_plan: true\n")
+	fmt.Fprintf(w, "parallel_apply: true\n")
+	fmt.Fprintf(w, "projects:\n")
+	for _, ws := range flattenPlan(plan) {
+		dependsOn := []string{}
+		for _, p := range producers(ws) {
+			if _, ok := group[p]; ok {
+				dependsOn = append(dependsOn, yamlString(names[p.Root]))
+			}
+		}
+
+		fmt.Fprintf(w, "  - name: %s\n", yamlString(names[ws.Root]))
+		fmt.Fprintf(w, "    dir: %s\n", yamlString(exportDir(ws.Root)))
+		fmt.Fprintf(w, "    execution_order_group: %d\n", group[ws])
+		fmt.Fprintf(w, "    depends_on: [%s]\n", strings.Join(dependsOn, ", "))
+		fmt.Fprintf(w, "    autoplan:\n")
+		fmt.Fprintf(w, "      enabled: true\n")
+		fmt.Fprintf(w, "      when_modified:\n")
+		for _, pattern := range whenModified(ws, "") {
+			fmt.Fprintf(w, "        - %s\n", yamlString(pattern))
+		}
+	}
+}
+
+// ExportTerrateam writes a Terrateam config with one dir entry per workspace
+// of the plan. Dependencies between workspaces are expressed as layered runs.
+func ExportTerrateam(w io.Writer, plan [][]*Workspace) {
+	inPlan := map[*Workspace]bool{}
+	for _, ws := range flattenPlan(plan) {
+		inPlan[ws] = true
+	}
+
+	fmt.Fprintf(w, "# generated by solaris, do not edit\n")
+	fmt.Fprintf(w, "dirs:\n")
+	for _, ws := range flattenPlan(plan) {
+		dependsOn := []string{}
+		for _, p := range producers(ws) {
+			if inPlan[p] {
+				dependsOn = append(dependsOn, "dir:"+exportDir(p.Root))
+			}
+		}
+
+		fmt.Fprintf(w, "  %s:\n", yamlString(exportDir(ws.Root)))
+		fmt.Fprintf(w, "    tags: [solaris]\n")
+		fmt.Fprintf(w, "    when_modified:\n")
+		if len(dependsOn) > 0 {
+			fmt.Fprintf(w, "      depends_on: %s\n", yamlString(strings.Join(dependsOn, " or ")))
+		}
+		fmt.Fprintf(w, "      file_patterns:\n")
+		for _, pattern := range whenModified(ws, exportDir(ws.Root)) {
+			fmt.Fprintf(w, "        - %s\n", yamlString(pattern))
+		}
+	}
+}
+
+// whenModified returns the patterns of files triggering a plan of the
+// workspace. Patterns are relative to dir, which is either the workspace
+// itself if empty or the directory given.
+func whenModified(ws *Workspace, dir string) []string {
+	prefix := ""
+	if dir != "" {
+		prefix = dir + "/"
+	}
+	out := []string{prefix + "*.tf", prefix + "*.tfvars"}
+	for _, source := range ws.Modules {
+		path := filepath.ToSlash(filepath.Clean(source))
+		if dir != "" {
+			path = exportDir(filepath.Join(ws.Root, source))
+		}
+		out = append(out, path+"/**/*.tf")
+	}
+	return out
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestExportAtlantisUniqueNames(t *testing.T) {
+	plan := [][]*Workspace{{{Root: "apps/api/"}, {Root: "apps-api/"}}}
+
+	var out bytes.Buffer
+	ExportAtlantis(&out, plan)
+	for _, name := range []string{`name: "apps-api"`, `name: "apps-api-2"`} {
+		if strings.Count(out.String(), name+"\n") != 1 {
+			t.Errorf("expected one project with %s, got:\n%s", name, out.String())
+		}
+	}
+}
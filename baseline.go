@@ -0,0 +1,152 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const lintBaselineVersion = 1
+
+// LintBaseline records known findings so that only new ones are reported.
+// Findings are identified by fingerprints that do not depend on line numbers,
+// files or the base directory solaris was run in.
+type LintBaseline struct {
+	Version  int                  `json:"version"`
+	Findings []LintBaselineRecord `json:"findings"`
+}
+
+// LintBaselineRecord is a finding recorded in a baseline. Count is the number
+// of findings sharing the fingerprint, everything else is informational.
+type LintBaselineRecord struct {
+	Fingerprint string `json:"fingerprint"`
+	Count       int    `json:"count"`
+	Rule        string `json:"rule"`
+	Workspace   string `json:"workspace"`
+	Subject     string `json:"subject"`
+}
+
+// Fingerprint identifies a finding by its rule, its subject and its workspace
+// relative to the base directory given. The file is left out so that moving
+// a block to another file of the workspace does not produce a new finding.
+func (f LintFinding) Fingerprint(base string) string {
+	rel := baseRelative(base)
+	sum := sha256.Sum256([]byte(strings.Join([]string{f.Rule, rel(f.Workspace), f.baseSubject(rel)}, "\x00")))
+	return fmt.Sprintf("%x", sum[:8])
+}
+
+// baseSubject returns the subject of the finding with rel applied to the
+// workspace roots it consists of, which only subjects of circular
+// dependencies do.
+func (f LintFinding) baseSubject(rel func(string) string) string {
+	if f.Rule != lintRuleCircularDependency {
+		return f.Subject
+	}
+	roots := strings.Split(f.Subject, ", ")
+	for i, root := range roots {
+		roots[i] = rel(root)
+	}
+	return strings.Join(roots, ", ")
+}
+
+// baseRelative returns a function stripping the base directory given from the
+// start of a path.
+func baseRelative(base string) func(string) string {
+	base = filepath.ToSlash(filepath.Clean(base))
+	if base == "." {
+		return func(s string) string { return s }
+	}
+	prefix := strings.TrimSuffix(base, "/") + "/"
+	return func(s string) string {
+		return strings.TrimPrefix(s, prefix)
+	}
+}
+
+// NewLintBaseline returns a baseline containing the findings given.
+func NewLintBaseline(findings []LintFinding, base string) LintBaseline {
+	rel := baseRelative(base)
+	b := LintBaseline{Version: lintBaselineVersion, Findings: []LintBaselineRecord{}}
+	index := map[string]int{}
+	for _, f := range findings {
+		fp := f.Fingerprint(base)
+		if i, ok := index[fp]; ok {
+			b.Findings[i].Count++
+			continue
+		}
+		index[fp] = len(b.Findings)
+		b.Findings = append(b.Findings, LintBaselineRecord{
+			Fingerprint: fp,
+			Count:       1,
+			Rule:        f.Rule,
+			Workspace:   rel(f.Workspace),
+			Subject:     f.baseSubject(rel),
+		})
+	}
+	sort.SliceStable(b.Findings, func(i, j int) bool {
+		a, c := b.Findings[i], b.Findings[j]
+		if a.Workspace != c.Workspace {
+			return a.Workspace < c.Workspace
+		}
+		if a.Rule != c.Rule {
+			return a.Rule < c.Rule
+		}
+		return a.Subject < c.Subject
+	})
+	return b
+}
+
+// ReadLintBaseline reads a baseline written by WriteLintBaseline.
+func ReadLintBaseline(path string) (LintBaseline, error) {
+	b := LintBaseline{}
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return b, err
+	}
+	err = json.Unmarshal(raw, &b)
+	if err != nil {
+		return b, fmt.Errorf("could not read lint baseline from '%s': %s", path, err.Error())
+	}
+	if b.Version != lintBaselineVersion {
+		return b, fmt.Errorf("lint baseline '%s' has unsupported version %d", path, b.Version)
+	}
+	return b, nil
+}
+
+// WriteLintBaseline writes the baseline given to the file at path.
+func WriteLintBaseline(path string, b LintBaseline) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = writeJSON(f, b)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Filter returns the findings not recorded in the baseline. If there are more
+// findings sharing a fingerprint than recorded, the additional ones are
+// returned.
+func (b LintBaseline) Filter(findings []LintFinding, base string) []LintFinding {
+	known := map[string]int{}
+	for _, r := range b.Findings {
+		known[r.Fingerprint] += r.Count
+	}
+	out := []LintFinding{}
+	for _, f := range findings {
+		fp := f.Fingerprint(base)
+		if known[fp] > 0 {
+			known[fp]--
+			continue
+		}
+		out = append(out, f)
+	}
+	return out
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestLintBaseline(t *testing.T) {
+	files := map[string]string{
+		"net/main.tf": tfBackend("net") + tfOutput("vpc_id", `"vpc"`) + tfOutput("spare", `"x"`),
+		"api/main.tf": tfBackend("api") + tfRemoteState("net", "net") + tfOutput("url", "data.terraform_remote_state.net.outputs.vpc_id"),
+	}
+	dir, cleanup := tempFixture(t, files)
+	defer cleanup()
+	defer chdir(t, dir)()
+
+	lint := func(base string) []LintFinding {
+		workspaces, err := GetWorkspaces(base, []string{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return LintFindings(workspaces)
+	}
+
+	path := filepath.Join(dir, "baseline.json")
+	if err := WriteLintBaseline(path, NewLintBaseline(lint("."), ".")); err != nil {
+		t.Fatal(err)
+	}
+	baseline, err := ReadLintBaseline(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(baseline.Findings) != 2 {
+		t.Errorf("baseline records %d findings, want 2", len(baseline.Findings))
+	}
+
+	tests := []struct {
+		name  string
+		write map[string]string
+		base  string
+		new   []string
+	}{
+		{"unchanged", nil, ".", []string{}},
+		{"other base directory", nil, dir, []string{}},
+		{"lines moved", map[string]string{"net/main.tf": "\n\n" + files["net/main.tf"]}, ".", []string{}},
+		{"new finding", map[string]string{"api/main.tf": files["api/main.tf"] + tfOutput("port", `"80"`)}, ".", []string{"unused-output api/"}},
+		{"new finding below other base directory", map[string]string{"api/main.tf": files["api/main.tf"] + tfOutput("port", `"80"`)}, dir, []string{"unused-output " + dir + "/api/"}},
+	}
+	for _, tt := range tests {
+		writeFixture(t, dir, files)
+		writeFixture(t, dir, tt.write)
+		if got := findingKeys(baseline.Filter(lint(tt.base), tt.base)); !equalStrings(got, tt.new) {
+			t.Errorf("%s: new findings are %q, want %q", tt.name, got, tt.new)
+		}
+	}
+
+	if err := ioutil.WriteFile(path, []byte(`{"version": 2, "findings": []}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadLintBaseline(path); err == nil {
+		t.Errorf("expected an error reading a baseline of an unsupported version")
+	}
+}
+
+func TestLintBaselineFingerprint(t *testing.T) {
+	finding := LintFinding{Rule: lintRuleDuplicateBackend, Workspace: "infra/net/", File: "main.tf", Subject: "state/infra/net"}
+
+	tests := []struct {
+		name    string
+		finding LintFinding
+		base    string
+		same    bool
+	}{
+		{"other base directory", LintFinding{Rule: lintRuleDuplicateBackend, Workspace: "net/", File: "main.tf", Subject: "state/infra/net"}, ".", true},
+		{"other file", LintFinding{Rule: lintRuleDuplicateBackend, Workspace: "infra/net/", File: "backend.tf", Subject: "state/infra/net"}, "infra", true},
+		{"other line", LintFinding{Rule: lintRuleDuplicateBackend, Workspace: "infra/net/", File: "main.tf", Line: 12, Subject: "state/infra/net"}, "infra", true},
+		{"base directory within the subject", LintFinding{Rule: lintRuleDuplicateBackend, Workspace: "infra/net/", File: "main.tf", Subject: "state/net"}, "infra", false},
+		{"other workspace", LintFinding{Rule: lintRuleDuplicateBackend, Workspace: "infra/api/", File: "main.tf", Subject: "state/infra/net"}, "infra", false},
+		{"other rule", LintFinding{Rule: lintRuleBackendDrift, Workspace: "infra/net/", File: "main.tf", Subject: "state/infra/net"}, "infra", false},
+	}
+	for _, tt := range tests {
+		if same := tt.finding.Fingerprint(tt.base) == finding.Fingerprint("infra"); same != tt.same {
+			t.Errorf("%s: fingerprints equal is %t, want %t", tt.name, same, tt.same)
+		}
+	}
+
+	cycle := LintFinding{Rule: lintRuleCircularDependency, Workspace: "infra/api/", Subject: "infra/api/, infra/net/"}
+	moved := LintFinding{Rule: lintRuleCircularDependency, Workspace: "api/", Subject: "api/, net/"}
+	if cycle.Fingerprint("infra") != moved.Fingerprint(".") {
+		t.Error("fingerprints of circular dependencies depend on the base directory")
+	}
+}
+
+func TestLintBaselineCounts(t *testing.T) {
+	finding := func(file string, line int) LintFinding {
+		return LintFinding{Rule: lintRuleInexistentInput, Workspace: "api/", File: file, Line: line, Subject: "data.terraform_remote_state.net.outputs.gone"}
+	}
+	baselined := []LintFinding{finding("main.tf", 3), finding("main.tf", 7)}
+	b := NewLintBaseline(baselined, ".")
+	if len(b.Findings) != 1 || b.Findings[0].Count != 2 {
+		t.Fatalf("baseline records %+v, want one record with count 2", b.Findings)
+	}
+
+	tests := []struct {
+		name     string
+		findings []LintFinding
+		new      int
+	}{
+		{"same occurrences", baselined, 0},
+		{"fewer occurrences", baselined[:1], 0},
+		{"moved to another file", []LintFinding{finding("inputs.tf", 1), finding("inputs.tf", 9)}, 0},
+		{"additional occurrence", append(baselined, finding("main.tf", 11)), 1},
+		{"two additional occurrences", append(baselined, finding("main.tf", 11), finding("outputs.tf", 2)), 2},
+	}
+	for _, tt := range tests {
+		if got := len(b.Filter(tt.findings, ".")); got != tt.new {
+			t.Errorf("%s: got %d new findings, want %d", tt.name, got, tt.new)
+		}
+	}
+}
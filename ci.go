@@ -0,0 +1,277 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	CIFormatGitHubActions = "github-actions"
+	CIFormatGitLab        = "gitlab"
+	CIFormatBuildkite     = "buildkite"
+)
+
+// CIOptions controls how pipelines are generated from an execution plan.
+type CIOptions struct {
+	// Command is executed in the root of each workspace.
+	Command string
+	// ManualGates adds jobs awaiting approval before workspaces with a
+	// PreManual and after workspaces with a PostManual.
+	ManualGates bool
+	// Environment is the GitHub environment used to request approvals.
+	Environment string
+}
+
+// ciJob is either a job executing the command in a workspace or a gate
+// awaiting approval of a manual step.
+type ciJob struct {
+	ID string
+	// Filter is the id of the workspace job, used to detect changes.
+	Filter string
+	Name   string
+	Dir    string
+	Needs  []string
+	Paths  []string
+	Manual Manual
+}
+
+func (j ciJob) isGate() bool {
+	return j.Manual != ""
+}
+
+// buildCIJobs returns the jobs in execution order. Dependencies between jobs
+// are derived from the inputs of the workspaces rather than from the tiers of
+// the plan.
+func buildCIJobs(plan [][]*Workspace, opts CIOptions) []ciJob {
+	jobs := []ciJob{}
+
+	workspaces := flattenPlan(plan)
+	roots := []string{}
+	for _, ws := range workspaces {
+		roots = append(roots, ws.Root)
+	}
+	// 'changes' is the job detecting changes in GitHub Actions
+	ids := exportIDs(roots, "changes")
+	taken := map[string]bool{"changes": true}
+	for _, id := range ids {
+		taken[id] = true
+	}
+
+	// the id of the job to wait for if a workspace is needed by another job
+	done := map[*Workspace]string{}
+	for _, ws := range workspaces {
+		id := ids[ws.Root]
+
+		paths := watchedPaths(ws)
+		for _, up := range upstreamWorkspaces(ws) {
+			paths = append(paths, watchedPaths(up)...)
+		}
+		paths = uniqueStrings(paths)
+
+		needs := []string{}
+		for _, p := range producers(ws) {
+			if n, ok := done[p]; ok {
+				needs = append(needs, n)
+			}
+		}
+		sort.Strings(needs)
+
+		if opts.ManualGates && ws.PreManual != "" {
+			gate := ciJob{
+				ID:     uniqueID(id+"-pre-manual", taken),
+				Filter: id,
+				Name:   exportDir(ws.Root) + " pre manual",
+				Dir:    exportDir(ws.Root),
+				Needs:  needs,
+				Paths:  paths,
+				Manual: ws.PreManual,
+			}
+			jobs = append(jobs, gate)
+			needs = []string{gate.ID}
+		}
+
+		jobs = append(jobs, ciJob{
+			ID:     id,
+			Filter: id,
+			Name:   exportDir(ws.Root),
+			Dir:    exportDir(ws.Root),
+			Needs:  needs,
+			Paths:  paths,
+		})
+		done[ws] = id
+
+		if opts.ManualGates && ws.PostManual != "" {
+			gate := ciJob{
+				ID:     uniqueID(id+"-post-manual", taken),
+				Filter: id,
+				Name:   exportDir(ws.Root) + " post manual",
+				Dir:    exportDir(ws.Root),
+				Needs:  []string{id},
+				Paths:  paths,
+				Manual: ws.PostManual,
+			}
+			jobs = append(jobs, gate)
+			done[ws] = gate.ID
+		}
+	}
+
+	return jobs
+}
+
+// ExportCI writes a pipeline definition in the format given with one job per
+// workspace of the plan.
+func ExportCI(w io.Writer, plan [][]*Workspace, format string, opts CIOptions) error {
+	jobs := buildCIJobs(plan, opts)
+	switch format {
+	case CIFormatGitHubActions:
+		exportGitHubActions(w, jobs, opts)
+	case CIFormatGitLab:
+		exportGitLab(w, jobs, opts)
+	case CIFormatBuildkite:
+		exportBuildkite(w, jobs, opts)
+	default:
+		return fmt.Errorf("CI format '%s' is not supported, use one of '%s', '%s' or '%s'", format, CIFormatGitHubActions, CIFormatGitLab, CIFormatBuildkite)
+	}
+	return nil
+}
+
+// printManual prints the manual passed in the environment of a gate. Manuals
+// are not part of the script so the shell never interprets them.
+const printManual = `printf '%s\n' "$SOLARIS_MANUAL"`
+
+func exportGitHubActions(w io.Writer, jobs []ciJob, opts CIOptions) {
+	fmt.Fprintf(w, "# generated by solaris, do not edit\n")
+	fmt.Fprintf(w, "name: solaris\n")
+	fmt.Fprintf(w, "on:\n  push:\n  pull_request:\n")
+	fmt.Fprintf(w, "jobs:\n")
+
+	// detect changes per workspace
+	fmt.Fprintf(w, "  changes:\n")
+	fmt.Fprintf(w, "    runs-on: ubuntu-latest\n")
+	fmt.Fprintf(w, "    outputs:\n")
+	for _, job := range jobs {
+		if !job.isGate() {
+			fmt.Fprintf(w, "      %s: ${{ steps.filter.outputs.%s }}\n", job.ID, job.ID)
+		}
+	}
+	fmt.Fprintf(w, "    steps:\n")
+	fmt.Fprintf(w, "      - uses: actions/checkout@v4\n")
+	fmt.Fprintf(w, "      - uses: dorny/paths-filter@v3\n")
+	fmt.Fprintf(w, "        id: filter\n")
+	fmt.Fprintf(w, "        with:\n")
+	fmt.Fprintf(w, "          filters: |\n")
+	for _, job := range jobs {
+		if job.isGate() {
+			continue
+		}
+		fmt.Fprintf(w, "            %s:\n", job.ID)
+		for _, p := range job.Paths {
+			fmt.Fprintf(w, "              - %s\n", yamlString(p))
+		}
+	}
+
+	for _, job := range jobs {
+		fmt.Fprintf(w, "  %s:\n", job.ID)
+		fmt.Fprintf(w, "    name: %s\n", yamlString(job.Name))
+		fmt.Fprintf(w, "    needs: [%s]\n", strings.Join(append([]string{"changes"}, job.Needs...), ", "))
+		fmt.Fprintf(w, "    if: ${{ !failure() && !cancelled() && needs.changes.outputs.%s == 'true' }}\n", job.Filter)
+		fmt.Fprintf(w, "    runs-on: ubuntu-latest\n")
+		if job.isGate() {
+			fmt.Fprintf(w, "    environment: %s\n", yamlString(opts.Environment))
+			fmt.Fprintf(w, "    steps:\n")
+			fmt.Fprintf(w, "      - name: manual\n")
+			fmt.Fprintf(w, "        env:\n")
+			fmt.Fprintf(w, "          SOLARIS_MANUAL: %s\n", yamlString(githubLiteral(string(job.Manual))))
+			fmt.Fprintf(w, "        run: %s\n", yamlString(printManual))
+			continue
+		}
+		fmt.Fprintf(w, "    steps:\n")
+		fmt.Fprintf(w, "      - uses: actions/checkout@v4\n")
+		fmt.Fprintf(w, "      - uses: hashicorp/setup-terraform@v3\n")
+		fmt.Fprintf(w, "      - name: terraform\n")
+		fmt.Fprintf(w, "        working-directory: %s\n", yamlString(job.Dir))
+		fmt.Fprintf(w, "        run: %s\n", yamlString(opts.Command))
+	}
+}
+
+func exportGitLab(w io.Writer, jobs []ciJob, opts CIOptions) {
+	fmt.Fprintf(w, "# generated by solaris, do not edit\n")
+	for _, job := range jobs {
+		fmt.Fprintf(w, "\n%s:\n", yamlString(job.ID))
+		fmt.Fprintf(w, "  needs:")
+		if len(job.Needs) == 0 {
+			fmt.Fprintf(w, " []\n")
+		} else {
+			fmt.Fprintf(w, "\n")
+			for _, n := range job.Needs {
+				fmt.Fprintf(w, "    - job: %s\n      optional: true\n", yamlString(n))
+			}
+		}
+		fmt.Fprintf(w, "  rules:\n")
+		fmt.Fprintf(w, "    - changes:\n")
+		for _, p := range job.Paths {
+			fmt.Fprintf(w, "        - %s\n", yamlString(p))
+		}
+		if job.isGate() {
+			fmt.Fprintf(w, "      when: manual\n")
+			fmt.Fprintf(w, "  allow_failure: false\n")
+			fmt.Fprintf(w, "  variables:\n")
+			fmt.Fprintf(w, "    SOLARIS_MANUAL:\n")
+			fmt.Fprintf(w, "      value: %s\n", yamlString(string(job.Manual)))
+			fmt.Fprintf(w, "      expand: false\n")
+			fmt.Fprintf(w, "  script:\n")
+			fmt.Fprintf(w, "    - %s\n", yamlString(printManual))
+			continue
+		}
+		fmt.Fprintf(w, "  script:\n")
+		fmt.Fprintf(w, "    - %s\n", yamlString("cd "+shellQuote(job.Dir)))
+		fmt.Fprintf(w, "    - %s\n", yamlString(opts.Command))
+	}
+}
+
+func exportBuildkite(w io.Writer, jobs []ciJob, opts CIOptions) {
+	fmt.Fprintf(w, "# generated by solaris, do not edit\n")
+	fmt.Fprintf(w, "steps:\n")
+	for _, job := range jobs {
+		if job.isGate() {
+			fmt.Fprintf(w, "  - block: %s\n", yamlString(job.Name))
+			fmt.Fprintf(w, "    prompt: %s\n", yamlString(buildkiteLiteral(strings.TrimSpace(string(job.Manual)))))
+		} else {
+			fmt.Fprintf(w, "  - label: %s\n", yamlString(job.Name))
+			fmt.Fprintf(w, "    command: %s\n", yamlString(buildkiteLiteral(fmt.Sprintf("cd %s && %s", shellQuote(job.Dir), opts.Command))))
+		}
+		fmt.Fprintf(w, "    key: %s\n", yamlString(job.ID))
+		fmt.Fprintf(w, "    depends_on: [%s]\n", strings.Join(quoteAll(job.Needs), ", "))
+		fmt.Fprintf(w, "    if_changed: %s\n", yamlString("{"+strings.Join(job.Paths, ",")+"}"))
+	}
+}
+
+// githubLiteral escapes expressions in s so that GitHub Actions passes the
+// text on as is instead of evaluating it.
+func githubLiteral(s string) string {
+	return strings.Replace(s, "${{", "${{ '${{' }}", -1)
+}
+
+// buildkiteLiteral escapes environment variables in s so that they are not
+// interpolated when the pipeline is uploaded.
+func buildkiteLiteral(s string) string {
+	return strings.Replace(s, "$", "$$", -1)
+}
+
+func indentLines(s, indent string) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(indent+line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func quoteAll(in []string) []string {
+	out := []string{}
+	for _, s := range in {
+		out = append(out, yamlString(s))
+	}
+	return out
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestBuildCIJobsUniqueIDs(t *testing.T) {
+	plan := [][]*Workspace{{
+		{Root: "apps/api/", PreManual: "check", PostManual: "verify"},
+		{Root: "apps-api/"},
+		{Root: "apps-api-pre-manual/"},
+		{Root: "changes/"},
+		{Root: ""},
+	}}
+
+	jobs := buildCIJobs(plan, CIOptions{ManualGates: true})
+	want := map[string]string{
+		"":                     "root",
+		"apps-api/":            "apps-api",
+		"apps-api-pre-manual/": "apps-api-pre-manual",
+		"apps/api/":            "apps-api-2",
+		"changes/":             "changes-2",
+	}
+	seen := map[string]bool{}
+	for _, job := range jobs {
+		if seen[job.ID] {
+			t.Errorf("job id '%s' is not unique", job.ID)
+		}
+		seen[job.ID] = true
+		if job.isGate() {
+			continue
+		}
+		if id := want[job.Dir+"/"]; job.Dir != "." && id != job.ID {
+			t.Errorf("job of '%s' has id '%s', want '%s'", job.Dir, job.ID, id)
+		}
+		if job.Filter != job.ID {
+			t.Errorf("job '%s' detects changes with filter '%s'", job.ID, job.Filter)
+		}
+	}
+	if len(jobs) != 7 {
+		t.Errorf("got %d jobs, want 7", len(jobs))
+	}
+	for _, id := range []string{"apps-api-2-pre-manual", "apps-api-2-post-manual"} {
+		if !seen[id] {
+			t.Errorf("gate '%s' is missing", id)
+		}
+	}
+}
+
+func TestExportCIManualIsNotEvaluated(t *testing.T) {
+	manual := "Run ${{ secrets.TOKEN }} with $HOME\nSOLARIS_MANUAL\necho done\n"
+	plan := [][]*Workspace{{{Root: "net/", PreManual: Manual(manual)}}}
+
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{CIFormatGitHubActions, []string{
+			`SOLARIS_MANUAL: "Run ${{ '${{' }} secrets.TOKEN }} with $HOME\nSOLARIS_MANUAL\necho done\n"`,
+			`run: "printf '%s\\n' \"$SOLARIS_MANUAL\""`,
+		}},
+		{CIFormatGitLab, []string{
+			`value: "Run ${{ secrets.TOKEN }} with $HOME\nSOLARIS_MANUAL\necho done\n"`,
+			`expand: false`,
+		}},
+		{CIFormatBuildkite, []string{
+			`prompt: "Run $${{ secrets.TOKEN }} with $$HOME\nSOLARIS_MANUAL\necho done"`,
+		}},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if err := ExportCI(&out, plan, tt.format, CIOptions{Command: "terraform apply", ManualGates: true}); err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("%s pipeline does not contain %s:\n%s", tt.format, want, out.String())
+			}
+		}
+		for _, line := range strings.Split(out.String(), "\n") {
+			if strings.TrimSpace(line) == "SOLARIS_MANUAL" || strings.TrimSpace(line) == "echo done" {
+				t.Errorf("%s pipeline contains the manual verbatim:\n%s", tt.format, out.String())
+			}
+		}
+	}
+}
+
+func TestExportCICommand(t *testing.T) {
+	plan := [][]*Workspace{{{Root: "my app/"}}}
+	opts := CIOptions{Command: `terraform apply -var "home=$HOME"`}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{CIFormatGitHubActions, `working-directory: "my app"`},
+		{CIFormatGitLab, `- "cd 'my app'"`},
+		{CIFormatBuildkite, `command: "cd 'my app' && terraform apply -var \"home=$$HOME\""`},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if err := ExportCI(&out, plan, tt.format, opts); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out.String(), tt.want) {
+			t.Errorf("%s pipeline does not contain %s:\n%s", tt.format, tt.want, out.String())
+		}
+	}
+}
@@ -3,11 +3,13 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
+	"strings"
+	"time"
 
-	"github.com/russross/blackfriday"
 	"github.com/spf13/cobra"
 )
 
@@ -20,6 +22,21 @@ type App struct {
 
 		// graph
 		graphDetailed bool
+		graphFormat   string
+		graphFocus    string
+		graphUp       int
+		graphDown     int
+		graphInclude  []string
+		graphExclude  []string
+		graphCollapse []string
+
+		// lint
+		lintFormat    string
+		lintFailOn    string
+		lintConfig    string
+		lintListRules bool
+		lintBaseline  string
+		lintWriteBase string
 
 		// json
 		jsonCompact bool
@@ -29,6 +46,44 @@ type App struct {
 		planJSON         bool
 		planRenderManual bool
 		planTemplate     string
+		planTemplateName string
+		planDumpTemplate bool
+		planSince        string
+		planStats        bool
+		planDurations    string
+		planRunLog       string
+		planConcurrency  int
+		planTimestamp    bool
+
+		// run
+		runRoots          []string
+		runNonInteractive string
+		runLog            string
+
+		// affected
+		affectedSince string
+
+		// impact
+		impactOutputs []string
+		impactSince   string
+		impactJSON    bool
+		impactTFPlan  string
+
+		// diff
+		diffFormat string
+
+		// docs
+		docsOut          string
+		docsRoots        []string
+		docsRenderManual bool
+		docsTimestamp    bool
+
+		// export
+		exportRoots         []string
+		exportCommand       string
+		exportCIFormat      string
+		exportCIManualGates bool
+		exportCIEnvironment string
 	}
 
 	// entry point
@@ -51,10 +106,17 @@ func NewApp() *App {
 	// graph
 	graphCmd := &cobra.Command{
 		Use:   "graph",
-		Short: "generate dot output of terraform workspace dependencies",
+		Short: "generate a graph of terraform workspace dependencies",
 		Run:   a.graphCmd,
 	}
 	graphCmd.PersistentFlags().BoolVar(&a.cfg.graphDetailed, "d", false, "draw a detailed graph")
+	graphCmd.PersistentFlags().StringVar(&a.cfg.graphFormat, "format", "dot", fmt.Sprintf("output format, one of '%s'", strings.Join(GraphFormats(), "', '")))
+	graphCmd.PersistentFlags().StringVar(&a.cfg.graphFocus, "focus", "", "only draw the workspace given and its upstream and downstream workspaces")
+	graphCmd.PersistentFlags().IntVar(&a.cfg.graphUp, "up", -1, "number of upstream levels drawn around the focused workspace, -1 for all")
+	graphCmd.PersistentFlags().IntVar(&a.cfg.graphDown, "down", -1, "number of downstream levels drawn around the focused workspace, -1 for all")
+	graphCmd.PersistentFlags().StringSliceVar(&a.cfg.graphInclude, "include", []string{}, "only draw workspaces matching these path globs")
+	graphCmd.PersistentFlags().StringSliceVar(&a.cfg.graphExclude, "exclude", []string{}, "do not draw workspaces matching these path globs")
+	graphCmd.PersistentFlags().StringSliceVar(&a.cfg.graphCollapse, "collapse", []string{}, "draw all workspaces matching a path glob as a single node, e.g. 'apps/*'")
 	rootCmd.AddCommand(graphCmd)
 
 	// lint
@@ -63,6 +125,12 @@ func NewApp() *App {
 		Short: "lint terraform workspace dependencies",
 		Run:   a.lintCmd,
 	}
+	lintCmd.PersistentFlags().StringVar(&a.cfg.lintFormat, "format", LintFormatText, fmt.Sprintf("output format, one of '%s'", strings.Join(lintFormats, "', '")))
+	lintCmd.PersistentFlags().StringVar(&a.cfg.lintConfig, "config", "", fmt.Sprintf("config file overriding rule severities, defaults to '%s' in the base directory", configFileName))
+	lintCmd.PersistentFlags().BoolVar(&a.cfg.lintListRules, "list-rules", false, "list all rules with their severity and exit")
+	lintCmd.PersistentFlags().StringVar(&a.cfg.lintBaseline, "baseline", "", "only report findings not recorded in this baseline file")
+	lintCmd.PersistentFlags().StringVar(&a.cfg.lintWriteBase, "write-baseline", "", "record all current findings in this baseline file and exit")
+	lintCmd.PersistentFlags().StringVar(&a.cfg.lintFailOn, "fail-on", lintSeverityError, "exit with a non-zero code if findings of this severity or worse are found, one of 'warning', 'error' or 'never'")
 	rootCmd.AddCommand(lintCmd)
 
 	// json
@@ -84,8 +152,123 @@ func NewApp() *App {
 	planCmd.PersistentFlags().BoolVar(&a.cfg.planJSON, "j", false, "print as JSON")
 	planCmd.PersistentFlags().BoolVar(&a.cfg.planRenderManual, "m", false, "Render Pre-/Post manuals (this requires `terraform` to be installed)")
 	planCmd.PersistentFlags().StringVar(&a.cfg.planTemplate, "t", "", "Path to template")
+	planCmd.PersistentFlags().StringVar(&a.cfg.planTemplateName, "template-name", defaultTemplateName, fmt.Sprintf("name of the built-in template to use if no path to a template is specified, one of '%s'", strings.Join(BuiltinTemplateNames(), "', '")))
+	planCmd.PersistentFlags().BoolVar(&a.cfg.planDumpTemplate, "dump-template", false, "print the source of the built-in template and exit")
+	planCmd.PersistentFlags().BoolVar(&a.cfg.planStats, "stats", false, "print critical path, tier widths and fan-in/fan-out of the plan instead of the plan")
+	planCmd.PersistentFlags().StringVar(&a.cfg.planDurations, "durations", "", "JSON file mapping workspace roots to their duration in seconds, used to estimate wall-clock time with --stats")
+	planCmd.PersistentFlags().StringVar(&a.cfg.planRunLog, "run-log", "", "run log written by 'solaris run', used to estimate wall-clock time with --stats")
+	planCmd.PersistentFlags().IntVar(&a.cfg.planConcurrency, "concurrency", 1, "number of workspaces executed concurrently when estimating wall-clock time")
+	planCmd.PersistentFlags().BoolVar(&a.cfg.planTimestamp, "timestamp", false, "include the time of generation in the rendered plan")
+	planCmd.PersistentFlags().StringVar(&a.cfg.planSince, "since", "", "plan only workspaces affected by changes since this git ref and workspaces depending on them")
 	rootCmd.AddCommand(planCmd)
 
+	// affected
+	affectedCmd := &cobra.Command{
+		Use:   "affected",
+		Short: "list terraform workspaces affected by changes since a git ref",
+		Run:   a.affectedCmd,
+	}
+	affectedCmd.PersistentFlags().StringVar(&a.cfg.affectedSince, "since", "", "git ref to compare the working tree with")
+	affectedCmd.MarkPersistentFlagRequired("since")
+	rootCmd.AddCommand(affectedCmd)
+
+	// impact
+	impactCmd := &cobra.Command{
+		Use:   "impact WORKSPACE",
+		Short: "list workspaces and manuals consuming outputs of a terraform workspace",
+		Args:  cobra.ExactArgs(1),
+		Run:   a.impactCmd,
+	}
+	impactCmd.PersistentFlags().StringSliceVar(&a.cfg.impactOutputs, "output", []string{}, "names of the outputs to analyse")
+	impactCmd.PersistentFlags().StringVar(&a.cfg.impactSince, "since", "", "analyse outputs whose blocks changed since this git ref")
+	impactCmd.PersistentFlags().StringVar(&a.cfg.impactTFPlan, "tfplan", "", "analyse outputs changed by a plan of the workspace saved with 'terraform show -json' and print the order to re-apply consumers in")
+	impactCmd.PersistentFlags().BoolVar(&a.cfg.impactJSON, "j", false, "print as JSON")
+	rootCmd.AddCommand(impactCmd)
+
+	// diff
+	diffCmd := &cobra.Command{
+		Use:   "diff REF_A REF_B",
+		Short: "compare terraform workspace dependencies between two git revisions",
+		Args:  cobra.ExactArgs(2),
+		Run:   a.diffCmd,
+	}
+	diffCmd.PersistentFlags().StringVar(&a.cfg.diffFormat, "format", "text", fmt.Sprintf("output format, one of 'text', 'json', '%s'", strings.Join(GraphFormats(), "', '")))
+	rootCmd.AddCommand(diffCmd)
+
+	// docs
+	docsCmd := &cobra.Command{
+		Use:   "docs",
+		Short: "generate a static HTML site documenting terraform workspaces in execution order",
+		Run:   a.docsCmd,
+	}
+	docsCmd.PersistentFlags().StringVar(&a.cfg.docsOut, "out", "docs", "directory to write the site to")
+	docsCmd.PersistentFlags().StringSliceVar(&a.cfg.docsRoots, "r", []string{}, "document only these workspaces and workspaces depending on them")
+	docsCmd.PersistentFlags().BoolVar(&a.cfg.docsRenderManual, "m", false, "Render Pre-/Post manuals (this requires `terraform` to be installed)")
+	docsCmd.PersistentFlags().BoolVar(&a.cfg.docsTimestamp, "timestamp", false, "include the time of generation in the site")
+	rootCmd.AddCommand(docsCmd)
+
+	// export
+	exportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "export the execution plan to other tools",
+	}
+	exportCmd.PersistentFlags().StringSliceVar(&a.cfg.exportRoots, "r", []string{}, "export only these workspaces and workspaces depending on them")
+	exportCmd.PersistentFlags().StringVar(&a.cfg.exportCommand, "command", "terraform init -input=false && terraform apply -input=false -auto-approve", "command to execute in each workspace")
+	rootCmd.AddCommand(exportCmd)
+
+	exportCICmd := &cobra.Command{
+		Use:   "ci",
+		Short: "generate a CI pipeline with one job per terraform workspace",
+		Run:   a.exportCICmd,
+	}
+	exportCICmd.PersistentFlags().StringVar(&a.cfg.exportCIFormat, "format", CIFormatGitHubActions, "pipeline format, one of 'github-actions', 'gitlab' or 'buildkite'")
+	exportCICmd.PersistentFlags().BoolVar(&a.cfg.exportCIManualGates, "manual-gates", false, "add jobs awaiting approval for Pre-/Post manuals")
+	exportCICmd.PersistentFlags().StringVar(&a.cfg.exportCIEnvironment, "environment", "manual-approval", "GitHub environment requiring approval used for manual gates")
+	exportCmd.AddCommand(exportCICmd)
+
+	exportAtlantisCmd := &cobra.Command{
+		Use:   "atlantis",
+		Short: "generate an atlantis.yaml with one project per terraform workspace",
+		Run:   a.exportAtlantisCmd,
+	}
+	exportCmd.AddCommand(exportAtlantisCmd)
+
+	exportTerrateamCmd := &cobra.Command{
+		Use:   "terrateam",
+		Short: "generate a Terrateam config with one dir per terraform workspace",
+		Run:   a.exportTerrateamCmd,
+	}
+	exportCmd.AddCommand(exportTerrateamCmd)
+
+	exportMakeCmd := &cobra.Command{
+		Use:   "make",
+		Short: "generate a Makefile with one target per terraform workspace",
+		Run:   a.exportMakeCmd,
+	}
+	exportCmd.AddCommand(exportMakeCmd)
+
+	exportShCmd := &cobra.Command{
+		Use:   "sh",
+		Short: "generate a shell script executing all terraform workspaces in order",
+		Run:   a.exportShCmd,
+	}
+	exportCmd.AddCommand(exportShCmd)
+
+	// run
+	runCmd := &cobra.Command{
+		Use:   "run -- COMMAND [ARGS...]",
+		Short: "run a command in each terraform workspace in execution order, pausing for manual steps",
+		Args:  cobra.MinimumNArgs(1),
+		RunE:  a.runCmd,
+		// errors are printed by main
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
+	runCmd.PersistentFlags().StringSliceVar(&a.cfg.runRoots, "r", []string{}, "run only these workspaces and workspaces depending on them")
+	runCmd.PersistentFlags().StringVar(&a.cfg.runNonInteractive, "non-interactive", "", "do not ask for confirmation of manual steps but 'fail', 'skip' (skipping a pre manual skips the workspace and the workspaces depending on it) or assume them 'done'")
+	runCmd.PersistentFlags().StringVar(&a.cfg.runLog, "log", "", "append a JSON line per executed step to this file")
+	rootCmd.AddCommand(runCmd)
+
 	// version
 	versionCmd := &cobra.Command{
 		Use:   "version",
@@ -103,33 +286,104 @@ func (a *App) debug(out string) {
 	}
 }
 
+// generatedAt returns the current time if timestamp is true and the zero time
+// otherwise, which keeps generated files reproducible.
+func generatedAt(timestamp bool) time.Time {
+	if timestamp {
+		return time.Now()
+	}
+	return time.Time{}
+}
+
 func (a *App) graphCmd(cmd *cobra.Command, args []string) {
 	workspaces, err := GetWorkspaces(a.cfg.rootBase, a.cfg.rootIgnorePatterns)
 	if err != nil {
 		log.Fatal(err)
 	}
-	if a.cfg.graphDetailed {
-		graph := RenderWorkspacesDetailed(workspaces)
-		fmt.Println(graph.String())
-	} else {
-		graph := RenderWorkspaces(workspaces)
-		fmt.Println(graph.String())
+	exporter, err := GetGraphExporter(a.cfg.graphFormat)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	filter := GraphFilter{
+		Base:     a.cfg.rootBase,
+		Include:  a.cfg.graphInclude,
+		Exclude:  a.cfg.graphExclude,
+		Up:       a.cfg.graphUp,
+		Down:     a.cfg.graphDown,
+		Collapse: a.cfg.graphCollapse,
+	}
+	if a.cfg.graphFocus != "" {
+		ws, err := FindWorkspace(workspaces, a.cfg.graphFocus)
+		if err != nil {
+			log.Fatal(err)
+		}
+		filter.Focus = ws.Root
+	}
+
+	cfg, err := ReadConfig("", a.cfg.rootBase)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	graph := BuildGraph(workspaces, a.cfg.graphDetailed)
+	graph.AnnotateLint(LintWorkspaces(workspaces, cfg.Lint))
+	graph, err = graph.Filter(filter)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = exporter.Export(os.Stdout, graph)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if a.cfg.graphFormat == "dot" {
+		fmt.Printf("\n/*\n   Use 'solaris ... | fdp -Tsvg > out.svg' or\n   similar to generate a vector visualization\n*/\n")
 	}
-	fmt.Printf("\n/*\n   Use 'solaris ... | fdp -Tsvg > out.svg' or\n   similar to generate a vector visualization\n*/\n")
 }
 
 func (a *App) lintCmd(cmd *cobra.Command, args []string) {
+	cfg, err := ReadConfig(a.cfg.lintConfig, a.cfg.rootBase)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if a.cfg.lintListRules {
+		PrintLintRules(os.Stdout, cfg.Lint)
+		return
+	}
+
 	workspaces, err := GetWorkspaces(a.cfg.rootBase, a.cfg.rootIgnorePatterns)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	errs := Lint(workspaces)
-	for k, v := range errs {
-		fmt.Printf("%s:\n", k)
-		for _, e := range v {
-			fmt.Printf("   %s\n", e)
+	findings := LintWorkspaces(workspaces, cfg.Lint)
+	if a.cfg.lintWriteBase != "" {
+		err = WriteLintBaseline(a.cfg.lintWriteBase, NewLintBaseline(findings, a.cfg.rootBase))
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(os.Stderr, "recorded %d findings in baseline '%s'\n", len(findings), a.cfg.lintWriteBase)
+		return
+	}
+	if a.cfg.lintBaseline != "" {
+		baseline, err := ReadLintBaseline(a.cfg.lintBaseline)
+		if err != nil {
+			log.Fatal(err)
 		}
+		findings = baseline.Filter(findings, a.cfg.rootBase)
+	}
+
+	fail, err := LintFails(findings, a.cfg.lintFailOn)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = WriteLintFindings(os.Stdout, findings, a.cfg.lintFormat)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if fail {
+		os.Exit(1)
 	}
 }
 
@@ -153,6 +407,15 @@ func (a *App) jsonCmd(cmd *cobra.Command, args []string) {
 }
 
 func (a *App) planCmd(cmd *cobra.Command, args []string) {
+	if a.cfg.planDumpTemplate {
+		tmpl, err := GetBuiltinTemplate(a.cfg.planTemplateName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print(tmpl)
+		return
+	}
+
 	workspaces, err := GetWorkspaces(a.cfg.rootBase, a.cfg.rootIgnorePatterns)
 	if err != nil {
 		log.Fatal(err)
@@ -162,41 +425,66 @@ func (a *App) planCmd(cmd *cobra.Command, args []string) {
 		wsarray = append(wsarray, ws)
 	}
 
-	plan, err := BuildExecutionPlan(wsarray, a.cfg.planRoots, a.debug)
-	if err != nil {
-		log.Fatal(err)
+	var plan [][]*Workspace
+	if a.cfg.planSince != "" {
+		// affected workspaces are matched exactly, roots given by -r as usual
+		roots, err := findRootWorkspaces(wsarray, a.cfg.planRoots)
+		if err != nil {
+			log.Fatal(err)
+		}
+		affected, err := a.affectedWorkspaces(workspaces, a.cfg.planSince)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(affected) == 0 {
+			fmt.Fprintf(os.Stderr, "no workspace is affected by changes since '%s'\n", a.cfg.planSince)
+			return
+		}
+		plan, err = BuildExecutionPlanFrom(wsarray, append(roots, affected...), a.debug)
+		if err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		plan, err = BuildExecutionPlan(wsarray, a.cfg.planRoots, a.debug)
+		if err != nil {
+			log.Fatal(err)
+		}
 	}
 
-	for tier, workspaces := range plan {
-		for i, ws := range workspaces {
-			if ws.PreManual != "" {
-				manual := ""
-				if a.cfg.planRenderManual {
-					manual, err = ws.PreManual.render(ws.Inputs)
-					if err != nil {
-						log.Fatal(err)
-					}
-				} else {
-					manual = string(ws.PreManual)
-				}
-				x := blackfriday.Run([]byte(manual))
-				plan[tier][i].PreManualRendered = string(x)
+	if a.cfg.planStats {
+		durations := Durations{}
+		if a.cfg.planRunLog != "" {
+			durations, err = ReadRunLogDurations(a.cfg.planRunLog)
+			if err != nil {
+				log.Fatal(err)
 			}
-			if ws.PostManual != "" {
-				manual := ""
-				if a.cfg.planRenderManual {
-					manual, err = ws.PostManual.render(ws.Inputs)
-					if err != nil {
-						log.Fatal(err)
-					}
-				} else {
-					manual = string(ws.PostManual)
-				}
+		}
+		if a.cfg.planDurations != "" {
+			d, err := ReadDurations(a.cfg.planDurations)
+			if err != nil {
+				log.Fatal(err)
+			}
+			for root, seconds := range d {
+				durations[root] = seconds
+			}
+		}
 
-				x := blackfriday.Run([]byte(manual))
-				plan[tier][i].PostManualRendered = string(x)
+		stats := GetPlanStats(plan, durations, a.cfg.planConcurrency)
+		if a.cfg.planJSON {
+			out, err := json.MarshalIndent(stats, "", "    ")
+			if err != nil {
+				log.Fatal(err)
 			}
+			fmt.Println(string(out))
+		} else {
+			PrintPlanStats(os.Stdout, stats)
 		}
+		return
+	}
+
+	err = RenderManuals(plan, a.cfg.planRenderManual)
+	if err != nil {
+		log.Fatal(err)
 	}
 
 	if a.cfg.planJSON {
@@ -206,20 +494,294 @@ func (a *App) planCmd(cmd *cobra.Command, args []string) {
 		}
 		fmt.Println(string(out))
 	} else {
-		if a.cfg.planTemplate == "" {
-			log.Fatal("template was not specified")
+		var tmpl string
+		if a.cfg.planTemplate != "" {
+			content, err := ioutil.ReadFile(a.cfg.planTemplate)
+			if err != nil {
+				log.Fatal(err)
+			}
+			tmpl = string(content)
+		} else {
+			tmpl, err = GetBuiltinTemplate(a.cfg.planTemplateName)
+			if err != nil {
+				log.Fatal(err)
+			}
 		}
-		content, err := ioutil.ReadFile(a.cfg.planTemplate)
+
+		out, err := RenderExecutionPlan(NewTemplateContext(plan, a.cfg.rootBase, generatedAt(a.cfg.planTimestamp)), tmpl)
 		if err != nil {
 			log.Fatal(err)
 		}
-
-		out := RenderExecutionPlan(plan, string(content))
 		fmt.Println(out)
 	}
 
 }
 
+func (a *App) affectedCmd(cmd *cobra.Command, args []string) {
+	workspaces, err := GetWorkspaces(a.cfg.rootBase, a.cfg.rootIgnorePatterns)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	affected, err := a.affectedWorkspaces(workspaces, a.cfg.affectedSince)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, ws := range affected {
+		fmt.Println(ws.Root)
+	}
+}
+
+func (a *App) affectedWorkspaces(workspaces map[string]*Workspace, since string) ([]*Workspace, error) {
+	files, err := gitChangedFiles(a.cfg.rootBase, since)
+	if err != nil {
+		return []*Workspace{}, err
+	}
+	a.debug(fmt.Sprintf("Files changed since %s:\n\t%s\n", since, strings.Join(files, "\n\t")))
+
+	return GetAffectedWorkspaces(workspaces, files)
+}
+
+func (a *App) impactCmd(cmd *cobra.Command, args []string) {
+	workspaces, err := GetWorkspaces(a.cfg.rootBase, a.cfg.rootIgnorePatterns)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ws, err := FindWorkspace(workspaces, args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	outputs := a.cfg.impactOutputs
+	if a.cfg.impactSince != "" {
+		changed, removed, err := GetChangedOutputs(ws, a.cfg.impactSince)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, name := range removed {
+			fmt.Fprintf(os.Stderr, "output '%s' of workspace '%s' has been removed since '%s', run 'solaris lint' to find inputs referring to it\n", name, ws.Root, a.cfg.impactSince)
+		}
+		outputs = append(outputs, changed...)
+	}
+	if a.cfg.impactTFPlan != "" {
+		tfplan, err := ReadTerraformPlan(a.cfg.impactTFPlan)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, name := range tfplan.ChangedOutputs() {
+			exists := false
+			for _, o := range ws.Outputs {
+				if o.Name == name {
+					exists = true
+				}
+			}
+			if exists {
+				outputs = append(outputs, name)
+			} else {
+				fmt.Fprintf(os.Stderr, "output '%s' changed by '%s' is not defined in workspace '%s', run 'solaris lint' to find inputs referring to it\n", name, a.cfg.impactTFPlan, ws.Root)
+			}
+		}
+		if len(outputs) == 0 {
+			fmt.Fprintf(os.Stderr, "'%s' does not change any output of workspace '%s'\n", a.cfg.impactTFPlan, ws.Root)
+			return
+		}
+	}
+	if len(outputs) == 0 {
+		log.Fatal("no outputs to analyse, use --output, --since or --tfplan")
+	}
+
+	impacts, err := GetOutputImpact(ws, outputs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	impacted := ImpactedWorkspaces(impacts)
+
+	var order [][]*Workspace
+	if a.cfg.impactTFPlan != "" {
+		wsarray := []*Workspace{}
+		for _, ws := range workspaces {
+			wsarray = append(wsarray, ws)
+		}
+		plan, err := BuildExecutionPlanFrom(wsarray, []*Workspace{ws}, a.debug)
+		if err != nil {
+			log.Fatal(err)
+		}
+		order = ReduceExecutionPlan(plan, impacted)
+	}
+
+	if a.cfg.impactJSON {
+		data := map[string]interface{}{"impact": impacts}
+		if order != nil {
+			data["order"] = order
+		}
+		out, err := json.MarshalIndent(data, "", "    ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(string(out))
+		return
+	}
+
+	PrintOutputImpact(os.Stdout, impacts)
+	fmt.Printf("\nAffected workspaces:\n")
+	for _, root := range impacted {
+		fmt.Printf("    %s\n", root)
+	}
+	if order != nil {
+		fmt.Printf("\nRe-apply order:\n")
+		for tier, workspaces := range order {
+			for _, ws := range workspaces {
+				fmt.Printf("    %d. %s\n", tier+1, ws.Root)
+			}
+		}
+	}
+}
+
+func (a *App) docsCmd(cmd *cobra.Command, args []string) {
+	workspaces, err := GetWorkspaces(a.cfg.rootBase, a.cfg.rootIgnorePatterns)
+	if err != nil {
+		log.Fatal(err)
+	}
+	wsarray := []*Workspace{}
+	for _, ws := range workspaces {
+		wsarray = append(wsarray, ws)
+	}
+
+	plan, err := BuildExecutionPlan(wsarray, a.cfg.docsRoots, a.debug)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = RenderManuals(plan, a.cfg.docsRenderManual)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = WriteDocs(a.cfg.docsOut, plan, generatedAt(a.cfg.docsTimestamp))
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (a *App) exportPlan() [][]*Workspace {
+	workspaces, err := GetWorkspaces(a.cfg.rootBase, a.cfg.rootIgnorePatterns)
+	if err != nil {
+		log.Fatal(err)
+	}
+	wsarray := []*Workspace{}
+	for _, ws := range workspaces {
+		wsarray = append(wsarray, ws)
+	}
+
+	plan, err := BuildExecutionPlan(wsarray, a.cfg.exportRoots, a.debug)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return plan
+}
+
+func (a *App) exportCICmd(cmd *cobra.Command, args []string) {
+	opts := CIOptions{
+		Command:     a.cfg.exportCommand,
+		ManualGates: a.cfg.exportCIManualGates,
+		Environment: a.cfg.exportCIEnvironment,
+	}
+	err := ExportCI(os.Stdout, a.exportPlan(), a.cfg.exportCIFormat, opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (a *App) exportAtlantisCmd(cmd *cobra.Command, args []string) {
+	ExportAtlantis(os.Stdout, a.exportPlan())
+}
+
+func (a *App) exportTerrateamCmd(cmd *cobra.Command, args []string) {
+	ExportTerrateam(os.Stdout, a.exportPlan())
+}
+
+func (a *App) exportMakeCmd(cmd *cobra.Command, args []string) {
+	ExportMakefile(os.Stdout, a.exportPlan(), a.cfg.exportCommand)
+}
+
+func (a *App) exportShCmd(cmd *cobra.Command, args []string) {
+	ExportShell(os.Stdout, a.exportPlan(), a.cfg.exportCommand)
+}
+
+func (a *App) runCmd(cmd *cobra.Command, args []string) error {
+	policy, err := NewManualPolicy(a.cfg.runNonInteractive)
+	if err != nil {
+		return err
+	}
+
+	workspaces, err := GetWorkspaces(a.cfg.rootBase, a.cfg.rootIgnorePatterns)
+	if err != nil {
+		return err
+	}
+	wsarray := []*Workspace{}
+	for _, ws := range workspaces {
+		wsarray = append(wsarray, ws)
+	}
+
+	plan, err := BuildExecutionPlan(wsarray, a.cfg.runRoots, a.debug)
+	if err != nil {
+		return err
+	}
+
+	var runLog io.Writer
+	if a.cfg.runLog != "" {
+		f, err := os.OpenFile(a.cfg.runLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		runLog = f
+	}
+
+	return NewRunner(args, policy, runLog, a.debug).Run(plan)
+}
+
+func (a *App) diffCmd(cmd *cobra.Command, args []string) {
+	var exporter GraphExporter
+	if a.cfg.diffFormat != "text" && a.cfg.diffFormat != "json" {
+		var err error
+		exporter, err = GetGraphExporter(a.cfg.diffFormat)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	from, err := GetWorkspacesAt(a.cfg.rootBase, a.cfg.rootIgnorePatterns, args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	to, err := GetWorkspacesAt(a.cfg.rootBase, a.cfg.rootIgnorePatterns, args[1])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch a.cfg.diffFormat {
+	case "text":
+		diff := DiffWorkspaces(from, to)
+		diff.From, diff.To = args[0], args[1]
+		PrintGraphDiff(os.Stdout, diff)
+	case "json":
+		diff := DiffWorkspaces(from, to)
+		diff.From, diff.To = args[0], args[1]
+		out, err := json.MarshalIndent(diff, "", "    ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(string(out))
+	default:
+		err = exporter.Export(os.Stdout, DiffGraph(from, to))
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
 func (a *App) versionCmd(cmd *cobra.Command, args []string) {
 	fmt.Println(versionInfo())
 }
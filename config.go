@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+const configFileName = ".solaris.json"
+
+// Config holds the settings of a repository, read from a .solaris.json file
+// in the base directory.
+type Config struct {
+	Lint LintConfig `json:"lint"`
+}
+
+// LintConfig overrides the severities of lint rules. Rules map rule ids to
+// 'off', 'warning' or 'error'. Paths override them for workspaces matching a
+// path glob, later entries take precedence. ExternalStates lists remote states
+// not managed in the repository.
+type LintConfig struct {
+	Rules          map[string]string   `json:"rules"`
+	Paths          []LintPathConfig    `json:"paths"`
+	ExternalStates []LintExternalState `json:"external_states"`
+}
+
+type LintPathConfig struct {
+	Path  string            `json:"path"`
+	Rules map[string]string `json:"rules"`
+}
+
+// LintExternalState matches remote states by bucket and a key glob, an empty
+// bucket matches all buckets.
+type LintExternalState struct {
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+}
+
+// ReadConfig reads the config file given. If path is empty the .solaris.json
+// in the base directory is read if it exists.
+func ReadConfig(path, base string) (Config, error) {
+	cfg := Config{}
+	if path == "" {
+		path = filepath.Join(base, configFileName)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return cfg, nil
+		}
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	err = json.Unmarshal(raw, &cfg)
+	if err != nil {
+		return cfg, fmt.Errorf("could not read config from '%s': %s", path, err.Error())
+	}
+
+	err = cfg.Lint.validate()
+	if err != nil {
+		return cfg, fmt.Errorf("config '%s' is invalid: %s", path, err.Error())
+	}
+	return cfg, nil
+}
+
+func (c LintConfig) validate() error {
+	all := []map[string]string{c.Rules}
+	for _, p := range c.Paths {
+		if _, err := filepath.Match(p.Path, ""); err != nil || p.Path == "" {
+			return fmt.Errorf("path '%s' is not a valid glob", p.Path)
+		}
+		all = append(all, p.Rules)
+	}
+	for _, e := range c.ExternalStates {
+		if _, err := path.Match(e.Key, ""); err != nil || e.Key == "" {
+			return fmt.Errorf("key '%s' of external state is not a valid glob", e.Key)
+		}
+	}
+	for _, rules := range all {
+		for id, severity := range rules {
+			if _, ok := GetLintRule(id); !ok {
+				return fmt.Errorf("lint rule '%s' does not exist", id)
+			}
+			switch severity {
+			case lintSeverityOff, lintSeverityWarning, lintSeverityError:
+			default:
+				return fmt.Errorf("severity '%s' of lint rule '%s' is invalid, use one of 'off', 'warning' or 'error'", severity, id)
+			}
+		}
+	}
+	return nil
+}
+
+// severity returns the severity of the rule given for a workspace.
+func (c LintConfig) severity(rule LintRule, root string) string {
+	severity := rule.Severity
+	if s, ok := c.Rules[rule.ID]; ok {
+		severity = s
+	}
+	for _, p := range c.Paths {
+		if s, ok := p.Rules[rule.ID]; ok && matchAnyPath([]string{p.Path}, root) != "" {
+			severity = s
+		}
+	}
+	return severity
+}
+
+// isExternal returns true if the remote state given is listed as external.
+func (c LintConfig) isExternal(rs RemoteState) bool {
+	for _, e := range c.ExternalStates {
+		if e.Bucket != "" && e.Bucket != rs.Bucket {
+			continue
+		}
+		if ok, _ := path.Match(e.Key, rs.Key); ok {
+			return true
+		}
+	}
+	return false
+}
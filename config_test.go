@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLintWorkspacesSeverities(t *testing.T) {
+	files := map[string]string{
+		"net/main.tf":        tfBackend("net") + tfOutput("vpc_id", `"vpc"`),
+		"legacy/db/main.tf":  tfBackend("db") + tfOutput("host", `"db"`),
+		"api/main.tf":        tfBackend("api") + "\n# solaris:ignore unused-output" + tfOutput("url", `"api"`) + tfOutput("port", `"80"`),
+		"web/main.tf":        tfBackend("web") + "\noutput \"host\" { # solaris:ignore\n  value = \"web\"\n}\n",
+		"dns/main.tf":        tfBackend("dns"),
+		"dns/PreManual.md":   "<!-- solaris:ignore manual-unknown-output -->\nCheck {{net.nope}}.\nCheck {{net.gone}}.\n",
+		"other/main.tf":      tfBackend("other") + "\n# solaris:ignore inexistent-input" + tfOutput("x", `"x"`),
+		"legacy/old/main.tf": tfBackend("old") + tfOutput("y", `"y"`),
+	}
+	dir, cleanup := tempFixture(t, files)
+	defer cleanup()
+	defer chdir(t, dir)()
+
+	workspaces, err := GetWorkspaces(".", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		cfg  LintConfig
+		want []string
+	}{
+		{
+			name: "defaults and suppressions",
+			cfg:  LintConfig{},
+			want: []string{
+				"unused-output warning api/ port",
+				"manual-unknown-output error dns/ {{net.gone}}",
+				"unused-output warning legacy/db/ host",
+				"unused-output warning legacy/old/ y",
+				"unused-output warning net/ vpc_id",
+				"unused-output warning other/ x",
+			},
+		},
+		{
+			name: "rule turned off",
+			cfg:  LintConfig{Rules: map[string]string{lintRuleUnusedOutput: lintSeverityOff}},
+			want: []string{"manual-unknown-output error dns/ {{net.gone}}"},
+		},
+		{
+			name: "paths override rules, later paths take precedence",
+			cfg: LintConfig{
+				Rules: map[string]string{lintRuleUnusedOutput: lintSeverityError, lintRuleManualUnknown: lintSeverityWarning},
+				Paths: []LintPathConfig{
+					{Path: "legacy/*", Rules: map[string]string{lintRuleUnusedOutput: lintSeverityOff}},
+					{Path: "legacy/db", Rules: map[string]string{lintRuleUnusedOutput: lintSeverityWarning}},
+				},
+			},
+			want: []string{
+				"unused-output error api/ port",
+				"manual-unknown-output warning dns/ {{net.gone}}",
+				"unused-output warning legacy/db/ host",
+				"unused-output error net/ vpc_id",
+				"unused-output error other/ x",
+			},
+		},
+	}
+	for _, tt := range tests {
+		got := []string{}
+		for _, f := range LintWorkspaces(workspaces, tt.cfg) {
+			got = append(got, fmt.Sprintf("%s %s %s %s", f.Rule, f.Severity, f.Workspace, f.Subject))
+		}
+		if !equalStrings(got, tt.want) {
+			t.Errorf("%s: findings are %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "solaris-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		config string
+		err    bool
+	}{
+		{`{"lint": {"rules": {"unused-output": "off"}, "paths": [{"path": "legacy/*", "rules": {"circular-dependency": "warning"}}]}}`, false},
+		{`{"lint": {"external_states": [{"bucket": "shared", "key": "dns/*"}]}}`, false},
+		{`{"lint": {"rules": {"unused-outputs": "off"}}}`, true},
+		{`{"lint": {"rules": {"unused-output": "info"}}}`, true},
+		{`{"lint": {"paths": [{"path": "[", "rules": {}}]}}`, true},
+		{`{"lint": {"paths": [{"path": "", "rules": {}}]}}`, true},
+		{`{"lint": {"external_states": [{"key": ""}]}}`, true},
+		{`{"lint": `, true},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, configFileName)
+		if err := ioutil.WriteFile(path, []byte(tt.config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := ReadConfig("", dir)
+		if (err != nil) != tt.err {
+			t.Errorf("reading %s returned error %v", tt.config, err)
+		}
+	}
+
+	os.Remove(filepath.Join(dir, configFileName))
+	if _, err := ReadConfig("", dir); err != nil {
+		t.Errorf("a missing config in the base directory is not an error: %s", err)
+	}
+	if _, err := ReadConfig(filepath.Join(dir, "missing.json"), dir); err == nil {
+		t.Errorf("expected an error for a missing config passed explicitly")
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+type cytoscapeExporter struct{}
+
+type cytoscapeElement struct {
+	Data map[string]string `json:"data"`
+}
+
+type cytoscapeElements struct {
+	Nodes []cytoscapeElement `json:"nodes"`
+	Edges []cytoscapeElement `json:"edges"`
+}
+
+// Export writes the graph as Cytoscape.js elements JSON. Outputs and inputs
+// of detailed graphs refer to their workspace as parent (compound nodes).
+func (cytoscapeExporter) Export(w io.Writer, g *Graph) error {
+	elements := cytoscapeElements{
+		Nodes: []cytoscapeElement{},
+		Edges: []cytoscapeElement{},
+	}
+
+	for _, n := range g.Nodes {
+		data := map[string]string{}
+		for k, v := range n.Data {
+			data[k] = v
+		}
+		data["id"] = n.ID
+		data["label"] = n.Label
+		data["kind"] = n.Kind
+		if n.Parent != "" {
+			data["parent"] = n.Parent
+		}
+		if n.Color != "" {
+			data["color"] = n.Color
+		}
+		if n.Fill != "" {
+			data["fill"] = n.Fill
+		}
+		if n.Shape != "" {
+			data["shape"] = n.Shape
+		}
+		if n.Group != "" {
+			data["group"] = n.Group
+		}
+		elements.Nodes = append(elements.Nodes, cytoscapeElement{Data: data})
+	}
+
+	for _, e := range g.Edges {
+		data := map[string]string{}
+		for k, v := range e.Data {
+			data[k] = v
+		}
+		data["id"] = e.ID
+		data["source"] = e.From
+		data["target"] = e.To
+		if e.Label != "" {
+			data["label"] = e.Label
+		}
+		if e.Color != "" {
+			data["color"] = e.Color
+		}
+		elements.Edges = append(elements.Edges, cytoscapeElement{Data: data})
+	}
+
+	out, err := json.MarshalIndent(map[string]interface{}{"elements": elements}, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(out))
+	return err
+}
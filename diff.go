@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// GraphDiff lists the workspaces, outputs, inputs and dependencies added or
+// removed between two revisions. Tiers lists the tiers of workspaces present
+// in both revisions whose tier in the execution plan changed.
+type GraphDiff struct {
+	From       string  `json:"from"`
+	To         string  `json:"to"`
+	Workspaces DiffSet `json:"workspaces"`
+	Outputs    DiffSet `json:"outputs"`
+	Inputs     DiffSet `json:"inputs"`
+	Edges      DiffSet `json:"edges"`
+	Tiers      DiffSet `json:"tiers"`
+}
+
+// DiffSet holds the elements only present in the newer (Added) or in the older
+// (Removed) revision, sorted.
+type DiffSet struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+// IsEmpty returns true if nothing was added or removed.
+func (d GraphDiff) IsEmpty() bool {
+	for _, set := range []DiffSet{d.Workspaces, d.Outputs, d.Inputs, d.Edges, d.Tiers} {
+		if len(set.Added) > 0 || len(set.Removed) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// DiffWorkspaces compares the workspaces discovered at two revisions.
+func DiffWorkspaces(from, to map[string]*Workspace) GraphDiff {
+	tiersFrom, tiersTo := tierKeys(from, to), tierKeys(to, from)
+	return GraphDiff{
+		Workspaces: diffSet(workspaceKeys(from), workspaceKeys(to)),
+		Outputs:    diffSet(outputKeys(from), outputKeys(to)),
+		Inputs:     diffSet(inputKeys(from), inputKeys(to)),
+		Edges:      diffSet(edgeKeys(BuildGraph(from, false)), edgeKeys(BuildGraph(to, false))),
+		Tiers:      diffSet(tiersFrom, tiersTo),
+	}
+}
+
+func diffSet(from, to map[string]bool) DiffSet {
+	set := DiffSet{Added: []string{}, Removed: []string{}}
+	for k := range to {
+		if !from[k] {
+			set.Added = append(set.Added, k)
+		}
+	}
+	for k := range from {
+		if !to[k] {
+			set.Removed = append(set.Removed, k)
+		}
+	}
+	sort.Strings(set.Added)
+	sort.Strings(set.Removed)
+	return set
+}
+
+func diffName(root string) string {
+	return strings.TrimSuffix(root, "/")
+}
+
+func workspaceKeys(workspaces map[string]*Workspace) map[string]bool {
+	keys := map[string]bool{}
+	for root := range workspaces {
+		keys[diffName(root)] = true
+	}
+	return keys
+}
+
+func outputKeys(workspaces map[string]*Workspace) map[string]bool {
+	keys := map[string]bool{}
+	for root, ws := range workspaces {
+		for _, output := range ws.Outputs {
+			keys[diffName(root)+"."+output.Name] = true
+		}
+	}
+	return keys
+}
+
+func inputKeys(workspaces map[string]*Workspace) map[string]bool {
+	keys := map[string]bool{}
+	for root, ws := range workspaces {
+		for _, input := range ws.Inputs {
+			keys[diffName(root)+": "+input.FullName] = true
+		}
+	}
+	return keys
+}
+
+// tierKeys returns the tiers of the workspaces also present in other.
+func tierKeys(workspaces, other map[string]*Workspace) map[string]bool {
+	keys := map[string]bool{}
+	for ws, tier := range workspaceTiers(workspaces) {
+		if _, ok := other[ws.Root]; ok {
+			keys[fmt.Sprintf("%s: tier %d", diffName(ws.Root), tier)] = true
+		}
+	}
+	return keys
+}
+
+func edgeKeys(g *Graph) map[string]bool {
+	keys := map[string]bool{}
+	for _, e := range g.Edges {
+		keys[diffName(e.From)+" -> "+diffName(e.To)] = true
+	}
+	return keys
+}
+
+// DiffGraph returns a simple graph containing the workspaces and dependencies
+// of both revisions. Elements added in the newer revision are coloured green,
+// removed ones red.
+func DiffGraph(from, to map[string]*Workspace) *Graph {
+	a, b := BuildGraph(from, false), BuildGraph(to, false)
+	g := &Graph{Nodes: []*GraphNode{}, Edges: []*GraphEdge{}}
+
+	inA, inB := map[string]bool{}, map[string]bool{}
+	for _, n := range a.Nodes {
+		inA[n.ID] = true
+	}
+	for _, n := range b.Nodes {
+		inB[n.ID] = true
+		if !inA[n.ID] {
+			n.Color = "green"
+			n.Data["diff"] = "added"
+		}
+		g.Nodes = append(g.Nodes, n)
+	}
+	for _, n := range a.Nodes {
+		if !inB[n.ID] {
+			n.Color = "red"
+			n.Data["diff"] = "removed"
+			g.Nodes = append(g.Nodes, n)
+		}
+	}
+	sort.SliceStable(g.Nodes, func(i, j int) bool { return g.Nodes[i].ID < g.Nodes[j].ID })
+
+	edgesA, edgesB := edgeKeys(a), edgeKeys(b)
+	for _, e := range b.Edges {
+		if !edgesA[diffName(e.From)+" -> "+diffName(e.To)] {
+			e.Color = "green"
+			e.Data["diff"] = "added"
+		}
+		g.Edges = append(g.Edges, e)
+	}
+	for _, e := range a.Edges {
+		if !edgesB[diffName(e.From)+" -> "+diffName(e.To)] {
+			e.Color = "red"
+			e.Data["diff"] = "removed"
+			g.Edges = append(g.Edges, e)
+		}
+	}
+	for i, e := range g.Edges {
+		e.ID = fmt.Sprintf("e%d", i)
+	}
+	return g
+}
+
+// PrintGraphDiff writes a human readable report of the diff given.
+func PrintGraphDiff(w io.Writer, d GraphDiff) {
+	if d.IsEmpty() {
+		fmt.Fprintf(w, "No changes in dependencies between %s and %s\n", d.From, d.To)
+		return
+	}
+	fmt.Fprintf(w, "Changes in dependencies between %s and %s\n", d.From, d.To)
+	sections := []struct {
+		title string
+		set   DiffSet
+	}{
+		{"Workspaces", d.Workspaces},
+		{"Outputs", d.Outputs},
+		{"Inputs", d.Inputs},
+		{"Dependencies", d.Edges},
+		{"Tiers", d.Tiers},
+	}
+	for _, s := range sections {
+		if len(s.set.Added) == 0 && len(s.set.Removed) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s:\n", s.title)
+		for _, a := range s.set.Added {
+			fmt.Fprintf(w, "    + %s\n", a)
+		}
+		for _, r := range s.set.Removed {
+			fmt.Fprintf(w, "    - %s\n", r)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// diffFixture commits a repository with two revisions, HEAD~1 and HEAD, and
+// returns its directory.
+func diffFixture(t *testing.T) (string, func()) {
+	dir, cleanup := gitFixture(t, map[string]string{
+		"net/main.tf": tfBackend("net") + tfOutput("vpc_id", `"vpc"`),
+		"api/main.tf": tfBackend("api") + tfRemoteState("net", "net") + tfOutput("url", "data.terraform_remote_state.net.outputs.vpc_id"),
+		"web/main.tf": tfBackend("web") + tfRemoteState("api", "api") + tfOutput("host", "data.terraform_remote_state.api.outputs.url"),
+		"old/main.tf": tfBackend("old"),
+	})
+	runGit(t, dir, "rm", "-q", "-r", "old")
+	runGit(t, dir, "mv", "web", "site")
+	writeFixture(t, dir, map[string]string{
+		"api/main.tf": tfBackend("api") + tfOutput("url", `"https://api"`),
+		"dns/main.tf": tfBackend("dns") + tfRemoteState("net", "net") + tfOutput("zone", "data.terraform_remote_state.net.outputs.vpc_id"),
+	})
+	runGit(t, dir, "add", "-A")
+	runGit(t, dir, "commit", "-q", "-m", "change")
+	return dir, cleanup
+}
+
+func TestDiffWorkspaces(t *testing.T) {
+	dir, cleanup := diffFixture(t)
+	defer cleanup()
+	defer chdir(t, dir)()
+
+	from, err := GetWorkspacesAt(".", []string{}, "HEAD~1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	to, err := GetWorkspacesAt(".", []string{}, "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := DiffWorkspaces(from, to)
+
+	tests := []struct {
+		name string
+		set  DiffSet
+		want DiffSet
+	}{
+		{"workspaces", d.Workspaces, DiffSet{Added: []string{"dns", "site"}, Removed: []string{"old", "web"}}},
+		{"outputs", d.Outputs, DiffSet{Added: []string{"dns.zone", "site.host"}, Removed: []string{"web.host"}}},
+		{"inputs", d.Inputs, DiffSet{
+			Added:   []string{"dns: data.terraform_remote_state.net.outputs.vpc_id", "site: data.terraform_remote_state.api.outputs.url"},
+			Removed: []string{"api: data.terraform_remote_state.net.outputs.vpc_id", "web: data.terraform_remote_state.api.outputs.url"},
+		}},
+		{"edges", d.Edges, DiffSet{Added: []string{"dns -> net", "site -> api"}, Removed: []string{"api -> net", "web -> api"}}},
+		{"tiers", d.Tiers, DiffSet{Added: []string{"api: tier 1"}, Removed: []string{"api: tier 2"}}},
+	}
+	for _, tt := range tests {
+		if !equalStrings(tt.set.Added, tt.want.Added) || !equalStrings(tt.set.Removed, tt.want.Removed) {
+			t.Errorf("%s: got added %q and removed %q, want added %q and removed %q", tt.name, tt.set.Added, tt.set.Removed, tt.want.Added, tt.want.Removed)
+		}
+	}
+
+	var out bytes.Buffer
+	d.From, d.To = "HEAD~1", "HEAD"
+	PrintGraphDiff(&out, d)
+	for _, line := range []string{"Changes in dependencies between HEAD~1 and HEAD", "Workspaces:\n    + dns\n    + site\n    - old\n    - web\n", "Tiers:\n    + api: tier 1\n    - api: tier 2\n"} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("report does not contain %q:\n%s", line, out.String())
+		}
+	}
+
+	same := DiffWorkspaces(to, to)
+	if !same.IsEmpty() {
+		t.Errorf("diff of a revision with itself is not empty: %+v", same)
+	}
+	out.Reset()
+	PrintGraphDiff(&out, GraphDiff{From: "HEAD", To: "HEAD"})
+	if got := out.String(); got != "No changes in dependencies between HEAD and HEAD\n" {
+		t.Errorf("report of an empty diff is %q", got)
+	}
+}
+
+func TestDiffGraph(t *testing.T) {
+	dir, cleanup := diffFixture(t)
+	defer cleanup()
+	defer chdir(t, dir)()
+
+	from, err := GetWorkspacesAt(".", []string{}, "HEAD~1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	to, err := GetWorkspacesAt(".", []string{}, "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := DiffGraph(from, to)
+
+	nodes := map[string]string{}
+	for _, n := range g.Nodes {
+		nodes[n.ID] = n.Data["diff"]
+	}
+	wantNodes := map[string]string{"api/": "", "net/": "", "dns/": "added", "site/": "added", "old/": "removed", "web/": "removed"}
+	for id, want := range wantNodes {
+		if got, ok := nodes[id]; !ok || got != want {
+			t.Errorf("node '%s' is marked '%s', want '%s'", id, got, want)
+		}
+	}
+	if len(nodes) != len(wantNodes) {
+		t.Errorf("got %d nodes, want %d", len(nodes), len(wantNodes))
+	}
+
+	edges := map[string]string{}
+	for _, e := range g.Edges {
+		edges[e.From+" -> "+e.To] = e.Color
+	}
+	wantEdges := map[string]string{"dns/ -> net/": "green", "site/ -> api/": "green", "api/ -> net/": "red", "web/ -> api/": "red"}
+	for key, want := range wantEdges {
+		if got := edges[key]; got != want {
+			t.Errorf("edge '%s' is coloured '%s', want '%s'", key, got, want)
+		}
+	}
+	if len(edges) != len(wantEdges) {
+		t.Errorf("got %d edges, want %d", len(edges), len(wantEdges))
+	}
+}
+
+func TestDiffWorkspacesMissingBase(t *testing.T) {
+	dir, cleanup := gitFixture(t, map[string]string{"README.md": "empty"})
+	defer cleanup()
+	writeFixture(t, dir, map[string]string{"infra/net/main.tf": tfBackend("net")})
+	runGit(t, dir, "add", "-A")
+	runGit(t, dir, "commit", "-q", "-m", "add infra")
+	defer chdir(t, dir)()
+
+	from, err := GetWorkspacesAt("infra", []string{}, "HEAD~1")
+	if err != nil {
+		t.Fatalf("base directory missing at the older revision: %s", err.Error())
+	}
+	if len(from) != 0 {
+		t.Errorf("got %d workspaces at a revision without the base directory, want none", len(from))
+	}
+	to, err := GetWorkspacesAt("infra", []string{}, "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := DiffWorkspaces(from, to)
+	if want := []string{"infra/net"}; !equalStrings(d.Workspaces.Added, want) || len(d.Workspaces.Removed) != 0 {
+		t.Errorf("workspaces added are %q and removed %q, want added %q", d.Workspaces.Added, d.Workspaces.Removed, want)
+	}
+
+	if _, err := GetWorkspacesAt("infra", []string{}, "unknown"); err == nil {
+		t.Errorf("expected an error for an unknown revision")
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// docsWorkspace holds everything rendered on the page of a single workspace.
+type docsWorkspace struct {
+	*Workspace
+	Tier       int
+	Page       string
+	Producers  []*Workspace
+	Consumers  []*Workspace
+	Files      []docsFile
+	PreManual  template.HTML
+	PostManual template.HTML
+}
+
+type docsFile struct {
+	Name    string
+	Content string
+}
+
+type docsIndex struct {
+	Tiers       [][]*docsWorkspace
+	Graph       template.HTML
+	GeneratedAt time.Time
+	Version     string
+}
+
+// WriteDocs writes a static HTML site documenting the workspaces of the plan
+// to the directory given. Manuals are expected to be rendered already, see
+// RenderManuals. The time of generation is omitted if generatedAt is zero.
+func WriteDocs(dir string, plan [][]*Workspace, generatedAt time.Time) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	docsPage := docsPages(plan)
+	funcs := template.FuncMap{
+		"add":  func(i, j int) int { return i + j },
+		"page": docsPage,
+		"join": func(sep string, s []string) string { return strings.Join(s, sep) },
+	}
+	indexTmpl, err := template.New("index").Funcs(funcs).Parse(docsLayout + docsIndexTemplate)
+	if err != nil {
+		return err
+	}
+	workspaceTmpl, err := template.New("workspace").Funcs(funcs).Parse(docsLayout + docsWorkspaceTemplate)
+	if err != nil {
+		return err
+	}
+
+	ctx := NewTemplateContext(plan, "", generatedAt)
+	index := docsIndex{
+		Tiers:       [][]*docsWorkspace{},
+		Graph:       template.HTML(tierGraphSVG(plan, docsPage)),
+		GeneratedAt: ctx.GeneratedAt,
+		Version:     ctx.Version,
+	}
+
+	for tier, workspaces := range plan {
+		docs := []*docsWorkspace{}
+		for _, ws := range flattenPlan([][]*Workspace{workspaces}) {
+			d := &docsWorkspace{
+				Workspace:  ws,
+				Tier:       tier + 1,
+				Page:       docsPage(ws),
+				Producers:  producers(ws),
+				Consumers:  consumers(ws),
+				Files:      []docsFile{},
+				PreManual:  template.HTML(ws.PreManualRendered),
+				PostManual: template.HTML(ws.PostManualRendered),
+			}
+			for name, file := range ws.Files {
+				d.Files = append(d.Files, docsFile{Name: name, Content: string(file.Raw)})
+			}
+			sort.Slice(d.Files, func(i, j int) bool { return d.Files[i].Name < d.Files[j].Name })
+
+			err := writeDocsPage(filepath.Join(dir, d.Page), workspaceTmpl, d)
+			if err != nil {
+				return err
+			}
+			docs = append(docs, d)
+		}
+		index.Tiers = append(index.Tiers, docs)
+	}
+
+	return writeDocsPage(filepath.Join(dir, "index.html"), indexTmpl, index)
+}
+
+func writeDocsPage(path string, tmpl *template.Template, data interface{}) error {
+	var out strings.Builder
+	err := tmpl.Execute(&out, data)
+	if err != nil {
+		return fmt.Errorf("could not render '%s': %s", path, err.Error())
+	}
+	return ioutil.WriteFile(path, []byte(out.String()), 0644)
+}
+
+// docsPages returns a function naming the page documenting a workspace. Pages
+// of workspaces in the plan are unique and never overwrite the index,
+// workspaces not in the plan have no page and get an empty name.
+func docsPages(plan [][]*Workspace) func(*Workspace) string {
+	roots := []string{}
+	for _, ws := range flattenPlan(plan) {
+		roots = append(roots, ws.Root)
+	}
+	ids := exportIDs(roots, "index")
+	return func(ws *Workspace) string {
+		id, ok := ids[ws.Root]
+		if !ok {
+			return ""
+		}
+		return id + ".html"
+	}
+}
+
+// tierGraphSVG draws the workspaces of the plan as boxes with one column per
+// tier and arrows from producers to consumers. Boxes link to href(ws).
+func tierGraphSVG(plan [][]*Workspace, href func(*Workspace) string) string {
+	const (
+		colWidth  = 240
+		rowHeight = 56
+		boxWidth  = 190
+		boxHeight = 34
+		margin    = 16
+	)
+
+	type point struct{ x, y int }
+	pos := map[*Workspace]point{}
+	rows := 0
+	for tier, workspaces := range plan {
+		for i, ws := range flattenPlan([][]*Workspace{workspaces}) {
+			pos[ws] = point{margin + tier*colWidth, margin + i*rowHeight}
+		}
+		if len(workspaces) > rows {
+			rows = len(workspaces)
+		}
+	}
+	width := 2*margin + len(plan)*colWidth - (colWidth - boxWidth)
+	height := 2*margin + rows*rowHeight - (rowHeight - boxHeight)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`, width, height, width, height)
+	fmt.Fprintf(&b, `<defs><marker id="arrow" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="8" markerHeight="8" orient="auto"><path d="M0,0 L10,5 L0,10 z" fill="#888"/></marker></defs>`)
+
+	workspaces := flattenPlan(plan)
+	for _, e := range dependencyEdges(workspaces) {
+		from, okFrom := pos[e.Producer]
+		to, okTo := pos[e.Consumer]
+		if !okFrom || !okTo {
+			continue
+		}
+		x1, y1 := from.x+boxWidth, from.y+boxHeight/2
+		x2, y2 := to.x, to.y+boxHeight/2
+		fmt.Fprintf(&b, `<path d="M%d,%d C%d,%d %d,%d %d,%d" fill="none" stroke="#888" marker-end="url(#arrow)"><title>%s</title></path>`,
+			x1, y1, (x1+x2)/2, y1, (x1+x2)/2, y2, x2, y2, html.EscapeString(strings.Join(e.Outputs, ", ")))
+	}
+
+	for _, ws := range workspaces {
+		p := pos[ws]
+		fmt.Fprintf(&b, `<a href="%s"><rect x="%d" y="%d" width="%d" height="%d" rx="4" fill="#eef4fb" stroke="#4a90d9"/>`, html.EscapeString(href(ws)), p.x, p.y, boxWidth, boxHeight)
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="middle" dominant-baseline="middle">%s</text></a>`, p.x+boxWidth/2, p.y+boxHeight/2, html.EscapeString(exportDir(ws.Root)))
+	}
+	b.WriteString(`</svg>`)
+	return b.String()
+}
+
+const docsLayout = `{{ define "head" }}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{ . }}</title>
+<style>
+body { font-family: sans-serif; max-width: 70em; margin: 2em auto; color: #222; }
+nav { margin-bottom: 1em; }
+section { margin-bottom: 2em; }
+table { border-collapse: collapse; }
+td, th { text-align: left; padding: 0.2em 1em 0.2em 0; vertical-align: top; }
+div.manual { background: #fdf6e3; padding: 0.5em 1em; border-radius: 4px; }
+div.graph { overflow: auto; }
+pre { background: #f4f4f4; padding: 0.5em; overflow: auto; }
+</style>
+</head>
+<body>
+{{ end }}{{ define "foot" }}</body>
+</html>
+{{ end }}`
+
+const docsIndexTemplate = `{{ template "head" "Workspaces" }}<h1>Workspaces</h1>
+<p>Generated by solaris {{ .Version }}{{ if not .GeneratedAt.IsZero }} on {{ .GeneratedAt.Format "2006-01-02 15:04" }}{{ end }}.</p>
+<section>
+<h2>Dependencies</h2>
+<div class="graph">{{ .Graph }}</div>
+</section>
+<section>
+<h2>Execution Plan</h2>
+{{ range $i, $tier := .Tiers }}<h3>Tier {{ add $i 1 }}</h3>
+<ul>
+{{ range $tier }}<li><a href="{{ .Page }}">{{ .Root }}</a>{{ if .PreManual }} (pre manual){{ end }}{{ if .PostManual }} (post manual){{ end }}</li>
+{{ end }}</ul>
+{{ end }}</section>
+{{ template "foot" }}`
+
+const docsWorkspaceTemplate = `{{ template "head" .Root }}<nav><a href="index.html">Workspaces</a></nav>
+<h1>{{ .Root }}</h1>
+<p>Tier {{ .Tier }}{{ if .RemoteState.Key }}, state <code>s3://{{ .RemoteState.Bucket }}/{{ .RemoteState.Key }}</code> ({{ .RemoteState.Profile }}, {{ .RemoteState.Region }}){{ end }}</p>
+{{ with .Producers }}<section>
+<h2>Depends on</h2>
+<ul>
+{{ range . }}<li>{{ if page . }}<a href="{{ page . }}">{{ .Root }}</a>{{ else }}{{ .Root }}{{ end }}</li>
+{{ end }}</ul>
+</section>
+{{ end }}{{ with .Consumers }}<section>
+<h2>Consumers</h2>
+<ul>
+{{ range . }}<li>{{ if page . }}<a href="{{ page . }}">{{ .Root }}</a>{{ else }}{{ .Root }}{{ end }}</li>
+{{ end }}</ul>
+</section>
+{{ end }}{{ if .PreManual }}<section>
+<h2>Before applying</h2>
+<div class="manual">{{ .PreManual }}</div>
+</section>
+{{ end }}{{ if .PostManual }}<section>
+<h2>After applying</h2>
+<div class="manual">{{ .PostManual }}</div>
+</section>
+{{ end }}{{ with .Outputs }}<section>
+<h2>Outputs</h2>
+<table>
+<tr><th>Name</th><th>File</th><th>Consumed by</th></tr>
+{{ range . }}<tr id="output-{{ .Name }}"><td>{{ .Name }}</td><td>{{ .InFile }}</td><td>{{ range .ReferedBy }}{{ if page .BelongsTo }}<a href="{{ page .BelongsTo }}">{{ .BelongsTo.Root }}</a>{{ else }}{{ .BelongsTo.Root }}{{ end }} ({{ join ", " .InFile }})<br>{{ end }}</td></tr>
+{{ end }}</table>
+</section>
+{{ end }}{{ with .Inputs }}<section>
+<h2>Inputs</h2>
+<table>
+<tr><th>Name</th><th>Files</th><th>Output of</th></tr>
+{{ range . }}<tr><td>{{ .FullName }}</td><td>{{ join ", " .InFile }}</td><td>{{ with .ReferesTo }}{{ if page .BelongsTo }}<a href="{{ page .BelongsTo }}#output-{{ .Name }}">{{ .BelongsTo.Root }}</a>{{ else }}{{ .BelongsTo.Root }}{{ end }}{{ else }}unknown{{ end }}</td></tr>
+{{ end }}</table>
+</section>
+{{ end }}<section>
+<h2>Source Files</h2>
+{{ range .Files }}<h3 id="file-{{ .Name }}">{{ .Name }}</h3>
+<pre>{{ .Content }}</pre>
+{{ end }}</section>
+{{ template "foot" }}`
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteDocsUniquePages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "solaris-docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	plan := [][]*Workspace{{{Root: "apps/api/"}, {Root: "apps-api/"}, {Root: "index/"}}}
+	if err := WriteDocs(dir, plan, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	pages := []string{"apps-api.html", "apps-api-2.html", "index.html", "index-2.html"}
+	for _, page := range pages {
+		if _, err := os.Stat(filepath.Join(dir, page)); err != nil {
+			t.Errorf("page '%s' was not written", page)
+		}
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != len(pages) {
+		t.Errorf("got %d files, want %d", len(files), len(pages))
+	}
+}
+
+func TestWriteDocsLinksOnlyDocumentedPages(t *testing.T) {
+	files := map[string]string{
+		"net/main.tf": tfBackend("net") + tfOutput("vpc_id", `"vpc"`),
+		"api/main.tf": tfBackend("api") + tfRemoteState("net", "net") + tfOutput("url", "data.terraform_remote_state.net.outputs.vpc_id"),
+		"web/main.tf": tfBackend("web") + tfRemoteState("api", "api") + tfOutput("host", "data.terraform_remote_state.api.outputs.url"),
+	}
+	src, cleanup := tempFixture(t, files)
+	defer cleanup()
+	out, err := ioutil.TempDir("", "solaris-docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(out)
+	defer chdir(t, src)()
+
+	workspaces, err := GetWorkspaces(".", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := BuildExecutionPlan(workspaceSlice(workspaces), []string{"api/"}, func(string) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteDocs(out, plan, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	pages, err := ioutil.ReadDir(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	links := regexp.MustCompile(`href="([^"#]+)`)
+	for _, page := range pages {
+		content, err := ioutil.ReadFile(filepath.Join(out, page.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range links.FindAllStringSubmatch(string(content), -1) {
+			if _, err := os.Stat(filepath.Join(out, m[1])); err != nil {
+				t.Errorf("page '%s' links to '%s' which was not written", page.Name(), m[1])
+			}
+		}
+		if strings.Contains(string(content), "Generated by solaris dirty on") {
+			t.Errorf("page '%s' contains the time of generation", page.Name())
+		}
+	}
+
+	api, err := ioutil.ReadFile(filepath.Join(out, "api.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(api), "<li>net/</li>") {
+		t.Errorf("api.html does not mention net/ as plain text:\n%s", api)
+	}
+}
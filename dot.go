@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/emicklei/dot"
+)
+
+type dotExporter struct{}
+
+var dotShapes = map[string]string{
+	nodeShapePreManual:  "invhouse",
+	nodeShapePostManual: "house",
+	nodeShapeManual:     "hexagon",
+}
+
+// Export writes the graph in the graphviz dot language. Workspaces of detailed
+// graphs are drawn as clusters containing clusters for outputs and inputs.
+// Workspaces are grouped into clusters by their owner and ranked by tier.
+// Detailed graphs are undirected, as they have always been.
+func (dotExporter) Export(w io.Writer, g *Graph) error {
+	graphType := dot.Directed
+	if g.Detailed {
+		graphType = dot.Undirected
+	}
+	d := dot.NewGraph(graphType)
+	d.Attr("newrank", "true")
+	nodes := map[string]dot.Node{}
+	groups := map[string]*dot.Graph{}
+
+	group := func(n *GraphNode) *dot.Graph {
+		if n.Group == "" {
+			return d
+		}
+		if _, ok := groups[n.Group]; !ok {
+			groups[n.Group] = d.Subgraph(n.Group, dot.ClusterOption{})
+		}
+		return groups[n.Group]
+	}
+
+	// draw workspaces
+	for _, ws := range g.roots() {
+		if !g.Detailed {
+			nodes[ws.ID] = dotNode(group(ws), ws)
+			if ws.Tier > 0 {
+				d.AddToSameRank(fmt.Sprintf("tier%d", ws.Tier), nodes[ws.ID])
+			}
+			continue
+		}
+
+		cluster := group(ws).Subgraph(ws.Label, dot.ClusterOption{})
+		cluster.Attr("tooltip", dataSummary(ws.Data))
+		if ws.Color != "" {
+			cluster.Attr("color", ws.Color)
+		}
+		if ws.Fill != "" {
+			cluster.Attr("style", "filled")
+			cluster.Attr("fillcolor", ws.Fill)
+		}
+		for _, child := range g.children(ws.ID) {
+			sub := cluster.Subgraph(child.Kind+"s", dot.ClusterOption{})
+			nodes[child.ID] = dotNode(sub, child)
+		}
+	}
+
+	// draw relations/dependencies
+	for _, e := range g.Edges {
+		edge := d.Edge(nodes[e.From], nodes[e.To])
+		if e.Label != "" {
+			edge.Label(e.Label)
+		}
+		if e.Color != "" {
+			edge.Attr("color", e.Color)
+		}
+		edge.Attr("tooltip", dataSummary(e.Data))
+	}
+
+	_, err := fmt.Fprintln(w, d.String())
+	return err
+}
+
+func dotNode(g *dot.Graph, n *GraphNode) dot.Node {
+	node := g.Node(n.ID).Label(n.Label).Attr("tooltip", dataSummary(n.Data))
+	if n.Color != "" {
+		node.Attr("color", n.Color)
+	}
+	if n.Fill != "" {
+		node.Attr("style", "filled")
+		node.Attr("fillcolor", n.Fill)
+	}
+	if shape, ok := dotShapes[n.Shape]; ok {
+		node.Attr("shape", shape)
+	}
+	return node
+}
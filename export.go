@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var nonIDChars = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
+
+// exportID turns a workspace root into an identifier usable as job, target
+// or project name.
+func exportID(root string) string {
+	id := strings.Trim(nonIDChars.ReplaceAllString(filepath.ToSlash(filepath.Clean(root)), "-"), "-")
+	if id == "" {
+		id = "root"
+	}
+	if id[0] >= '0' && id[0] <= '9' {
+		id = "ws-" + id
+	}
+	return id
+}
+
+// exportIDs returns an identifier per root given that is unique among them
+// and differs from the reserved identifiers. Roots whose identifiers collide,
+// e.g. 'apps/api' and 'apps-api', are told apart by a numeric suffix in the
+// order of their roots.
+func exportIDs(roots []string, reserved ...string) map[string]string {
+	sorted := append([]string{}, roots...)
+	sort.Strings(sorted)
+	ids := map[string]string{}
+	taken := map[string]bool{}
+	for _, id := range reserved {
+		taken[id] = true
+	}
+	for _, root := range sorted {
+		if _, ok := ids[root]; !ok {
+			ids[root] = uniqueID(exportID(root), taken)
+		}
+	}
+	return ids
+}
+
+// uniqueID returns id or, if it is already taken, id with the lowest numeric
+// suffix that is not and marks the result as taken.
+func uniqueID(id string, taken map[string]bool) string {
+	out := id
+	for i := 2; taken[out]; i++ {
+		out = fmt.Sprintf("%s-%d", id, i)
+	}
+	taken[out] = true
+	return out
+}
+
+// exportDir returns the root of a workspace as clean, slash separated path
+// as used in generated configuration files.
+func exportDir(root string) string {
+	return filepath.ToSlash(filepath.Clean(root))
+}
+
+// flattenPlan returns the workspaces of an execution plan in execution order.
+func flattenPlan(plan [][]*Workspace) []*Workspace {
+	out := []*Workspace{}
+	for _, workspaces := range plan {
+		sorted := make([]*Workspace, len(workspaces))
+		copy(sorted, workspaces)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i].Root < sorted[j].Root })
+		out = append(out, sorted...)
+	}
+	return out
+}
+
+// upstreamWorkspaces returns all workspaces the workspace passed depends on
+// directly or indirectly, sorted by their root.
+func upstreamWorkspaces(ws *Workspace) []*Workspace {
+	visited := map[*Workspace]bool{ws: true}
+	out := []*Workspace{}
+	var collect func(*Workspace)
+	collect = func(ws *Workspace) {
+		for _, p := range producers(ws) {
+			if !visited[p] {
+				visited[p] = true
+				out = append(out, p)
+				collect(p)
+			}
+		}
+	}
+	collect(ws)
+	sort.Slice(out, func(i, j int) bool { return out[i].Root < out[j].Root })
+	return out
+}
+
+// watchedPaths returns glob patterns relative to the base directory matching
+// all files of a workspace and of the local modules it uses.
+func watchedPaths(ws *Workspace) []string {
+	out := []string{exportDir(ws.Root) + "/**"}
+	for _, source := range ws.Modules {
+		out = append(out, exportDir(filepath.Join(ws.Root, source))+"/**")
+	}
+	return out
+}
+
+// uniqueStrings returns the strings given without duplicates, keeping the
+// order of their first occurrence.
+func uniqueStrings(in []string) []string {
+	out := []string{}
+	seen := map[string]bool{}
+	for _, s := range in {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// yamlString quotes a string to be used as scalar in YAML documents.
+func yamlString(s string) string {
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSpace(out.String())
+}
+
+// shellQuote quotes a string to be used as a single word in POSIX shells.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// GraphFilter selects the workspaces drawn in a graph.
+type GraphFilter struct {
+	// Base is the directory workspace roots are relative to, path globs are
+	// matched against roots relative to it.
+	Base string
+	// Include and Exclude are path globs matched against workspace roots and
+	// their parent directories. If Include is empty all workspaces are kept.
+	Include []string
+	Exclude []string
+	// Focus is the root of a workspace, only workspaces up to Up levels
+	// upstream and Down levels downstream of it are kept. Negative depths
+	// are unlimited.
+	Focus string
+	Up    int
+	Down  int
+	// Collapse is a list of path globs, all workspaces matching the same
+	// glob are merged into a single node.
+	Collapse []string
+}
+
+// Filter returns a copy of the graph containing only the workspaces selected
+// by the filter given.
+func (g *Graph) Filter(f GraphFilter) (*Graph, error) {
+	for _, pattern := range append(append(append([]string{}, f.Include...), f.Exclude...), f.Collapse...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("pattern '%s' is invalid: %s", pattern, err.Error())
+		}
+	}
+
+	keep := map[string]bool{}
+	for _, n := range g.roots() {
+		root := f.relative(n.ID)
+		if (len(f.Include) == 0 || matchAnyPath(f.Include, root) != "") && matchAnyPath(f.Exclude, root) == "" {
+			keep[n.ID] = true
+		}
+	}
+
+	if f.Focus != "" {
+		if !keep[f.Focus] {
+			return nil, fmt.Errorf("Workspace '%s' is not part of the graph", f.Focus)
+		}
+		up, down := map[string][]string{}, map[string][]string{}
+		for _, e := range g.workspaceEdges() {
+			if keep[e.From] && keep[e.To] {
+				up[e.From] = append(up[e.From], e.To)
+				down[e.To] = append(down[e.To], e.From)
+			}
+		}
+		focused := map[string]bool{f.Focus: true}
+		walkGraph(f.Focus, up, f.Up, focused)
+		walkGraph(f.Focus, down, f.Down, focused)
+		keep = focused
+	}
+
+	// map workspaces to the node they are drawn as
+	merged := map[string]string{}
+	for id := range keep {
+		merged[id] = id
+		if pattern := matchAnyPath(f.Collapse, f.relative(id)); pattern != "" {
+			merged[id] = pattern
+		}
+	}
+
+	out := &Graph{Detailed: g.Detailed, Nodes: []*GraphNode{}, Edges: []*GraphEdge{}}
+	nodes := map[string]*GraphNode{}
+	collapsed := map[string][]string{}
+	for _, n := range g.Nodes {
+		target, ok := merged[n.Workspace]
+		if !ok {
+			continue
+		}
+		c := *n
+		c.Workspace = target
+		if n.Parent != "" {
+			c.Parent = target
+		} else if target != n.ID {
+			collapsed[target] = append(collapsed[target], n.ID)
+			if _, ok := nodes[target]; ok {
+				continue
+			}
+			c = GraphNode{ID: target, Kind: nodeKindWorkspace, Workspace: target, Data: map[string]string{}}
+		}
+		nodes[c.ID] = &c
+		out.Nodes = append(out.Nodes, &c)
+	}
+	for id, members := range collapsed {
+		sort.Strings(members)
+		n := nodes[id]
+		n.Label = fmt.Sprintf("%s (%d)", id, len(members))
+		n.Data["workspaces"] = strings.Join(members, ", ")
+		lint := []string{}
+		for i, m := range members {
+			member := g.node(m)
+			if l := member.Data["lint"]; l != "" {
+				lint = append(lint, l)
+			}
+			if member.Color != "" {
+				n.Color = member.Color
+			}
+			// keep the owner if shared by all members and the earliest tier
+			if i == 0 {
+				n.Group, n.Tier, n.Fill = member.Group, member.Tier, member.Fill
+			}
+			if member.Group != n.Group {
+				n.Group = ""
+			}
+			if member.Tier > 0 && (n.Tier == 0 || member.Tier < n.Tier) {
+				n.Tier, n.Fill = member.Tier, member.Fill
+			}
+		}
+		if len(lint) > 0 {
+			n.Data["lint"] = strings.Join(lint, "\n")
+		}
+	}
+
+	index := map[string]*GraphEdge{}
+	for _, e := range g.Edges {
+		from, to := e.From, e.To
+		if !g.Detailed {
+			from, to = merged[e.From], merged[e.To]
+		}
+		if nodes[from] == nil || nodes[to] == nil || from == to {
+			continue
+		}
+		key := from + "\x00" + to
+		if existing, ok := index[key]; ok {
+			existing.Label = mergeList(existing.Label, e.Label)
+			for k, v := range e.Data {
+				existing.Data[k] = mergeList(existing.Data[k], v)
+			}
+			continue
+		}
+		c := *e
+		c.ID = fmt.Sprintf("e%d", len(out.Edges))
+		c.From, c.To = from, to
+		c.Data = map[string]string{}
+		for k, v := range e.Data {
+			c.Data[k] = v
+		}
+		index[key] = &c
+		out.Edges = append(out.Edges, &c)
+	}
+
+	return out, nil
+}
+
+// relative returns the root given relative to the base of the filter.
+func (f GraphFilter) relative(root string) string {
+	rel, err := filepath.Rel(f.Base, root)
+	if err != nil {
+		return root
+	}
+	return rel
+}
+
+// mergeList returns the union of two comma separated lists, as used for the
+// labels and data of edges.
+func mergeList(a, b string) string {
+	values := []string{}
+	for _, list := range []string{a, b} {
+		if list != "" {
+			values = append(values, strings.Split(list, ", ")...)
+		}
+	}
+	return strings.Join(uniqueStrings(values), ", ")
+}
+
+// node returns the node with the id given or nil.
+func (g *Graph) node(id string) *GraphNode {
+	for _, n := range g.Nodes {
+		if n.ID == id {
+			return n
+		}
+	}
+	return nil
+}
+
+// walkGraph adds all nodes reachable from id within depth steps to visited.
+func walkGraph(id string, next map[string][]string, depth int, visited map[string]bool) {
+	seen := map[string]bool{id: true}
+	current := []string{id}
+	for step := 0; len(current) > 0 && step != depth; step++ {
+		following := []string{}
+		for _, c := range current {
+			for _, n := range next[c] {
+				if !seen[n] {
+					seen[n] = true
+					visited[n] = true
+					following = append(following, n)
+				}
+			}
+		}
+		current = following
+	}
+}
+
+// matchAnyPath returns the first pattern matching the root given or one of
+// its parent directories, or an empty string if none matches.
+func matchAnyPath(patterns []string, root string) string {
+	for _, pattern := range patterns {
+		for p := filepath.Clean(root); p != "." && p != "/"; p = filepath.Dir(p) {
+			if ok, _ := filepath.Match(filepath.Clean(pattern), p); ok {
+				return pattern
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"sort"
+	"testing"
+)
+
+// filterFixture returns a graph of workspaces below infra/, web depends on
+// api and both api and web depend on net.
+func filterFixture() *Graph {
+	node := func(id string) *GraphNode {
+		return &GraphNode{ID: id, Label: id, Kind: nodeKindWorkspace, Workspace: id, Data: map[string]string{}}
+	}
+	edge := func(id, from, to, outputs string) *GraphEdge {
+		return &GraphEdge{ID: id, From: from, To: to, Label: outputs, Data: map[string]string{"outputs": outputs}}
+	}
+	return &Graph{
+		Nodes: []*GraphNode{node("infra/apps/api/"), node("infra/apps/web/"), node("infra/net/")},
+		Edges: []*GraphEdge{
+			edge("e0", "infra/apps/web/", "infra/apps/api/", "url"),
+			edge("e1", "infra/apps/api/", "infra/net/", "vpc_id"),
+			edge("e2", "infra/apps/web/", "infra/net/", "vpc_id, zone"),
+		},
+	}
+}
+
+func graphNodeIDs(g *Graph) []string {
+	ids := []string{}
+	for _, n := range g.Nodes {
+		ids = append(ids, n.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestGraphFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter GraphFilter
+		nodes  []string
+	}{
+		{"no filter", GraphFilter{Base: "infra"}, []string{"infra/apps/api/", "infra/apps/web/", "infra/net/"}},
+		{"include relative to the base", GraphFilter{Base: "infra", Include: []string{"apps/*"}}, []string{"infra/apps/api/", "infra/apps/web/"}},
+		{"include parent directory", GraphFilter{Base: "infra", Include: []string{"apps"}}, []string{"infra/apps/api/", "infra/apps/web/"}},
+		{"exclude relative to the base", GraphFilter{Base: "infra", Exclude: []string{"apps/web"}}, []string{"infra/apps/api/", "infra/net/"}},
+		{"include with other base", GraphFilter{Base: ".", Include: []string{"apps/*"}}, []string{}},
+		{"focus upstream", GraphFilter{Base: "infra", Focus: "infra/apps/web/", Up: -1, Down: -1}, []string{"infra/apps/api/", "infra/apps/web/", "infra/net/"}},
+		{"focus downstream", GraphFilter{Base: "infra", Focus: "infra/apps/api/", Up: 0, Down: -1}, []string{"infra/apps/api/", "infra/apps/web/"}},
+		{"focus on remaining workspaces", GraphFilter{Base: "infra", Focus: "infra/net/", Up: -1, Down: 1, Exclude: []string{"apps/api"}}, []string{"infra/apps/web/", "infra/net/"}},
+		{"focus without downstream", GraphFilter{Base: "infra", Focus: "infra/apps/api/", Up: 1, Down: 0}, []string{"infra/apps/api/", "infra/net/"}},
+		{"collapse relative to the base", GraphFilter{Base: "infra", Collapse: []string{"apps/*"}}, []string{"apps/*", "infra/net/"}},
+	}
+	for _, tt := range tests {
+		g, err := filterFixture().Filter(tt.filter)
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err.Error())
+			continue
+		}
+		if got := graphNodeIDs(g); !equalStrings(got, tt.nodes) {
+			t.Errorf("%s: nodes are %q, want %q", tt.name, got, tt.nodes)
+		}
+	}
+
+	for _, f := range []GraphFilter{{Include: []string{"["}}, {Focus: "infra/unknown/"}} {
+		if _, err := filterFixture().Filter(f); err == nil {
+			t.Errorf("expected an error filtering with %+v", f)
+		}
+	}
+}
+
+func TestGraphFilterCollapsedEdges(t *testing.T) {
+	g, err := filterFixture().Filter(GraphFilter{Base: "infra", Collapse: []string{"apps/*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Edges) != 1 {
+		t.Fatalf("got %d edges, want 1", len(g.Edges))
+	}
+	e := g.Edges[0]
+	if e.From != "apps/*" || e.To != "infra/net/" {
+		t.Errorf("edge leads from '%s' to '%s', want from 'apps/*' to 'infra/net/'", e.From, e.To)
+	}
+	if e.Label != "vpc_id, zone" || e.Data["outputs"] != "vpc_id, zone" {
+		t.Errorf("edge is labeled '%s' with outputs '%s', want 'vpc_id, zone'", e.Label, e.Data["outputs"])
+	}
+	n := g.node("apps/*")
+	if n == nil || n.Label != "apps/* (2)" || n.Data["workspaces"] != "infra/apps/api/, infra/apps/web/" {
+		t.Errorf("collapsed node is %+v", n)
+	}
+}
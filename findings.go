@@ -0,0 +1,315 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	LintFormatText       = "text"
+	LintFormatJSON       = "json"
+	LintFormatSARIF      = "sarif"
+	LintFormatJUnit      = "junit"
+	LintFormatCheckstyle = "checkstyle"
+	LintFormatGitHub     = "github"
+)
+
+var lintFormats = []string{LintFormatText, LintFormatJSON, LintFormatSARIF, LintFormatJUnit, LintFormatCheckstyle, LintFormatGitHub}
+
+// WriteLintFindings writes the findings given in the format given.
+func WriteLintFindings(w io.Writer, findings []LintFinding, format string) error {
+	switch format {
+	case LintFormatText:
+		writeLintText(w, findings)
+		return nil
+	case LintFormatJSON:
+		return writeJSON(w, findings)
+	case LintFormatSARIF:
+		return writeLintSARIF(w, findings)
+	case LintFormatJUnit:
+		return writeLintJUnit(w, findings)
+	case LintFormatCheckstyle:
+		return writeLintCheckstyle(w, findings)
+	case LintFormatGitHub:
+		writeLintGitHub(w, findings)
+		return nil
+	}
+	return fmt.Errorf("lint format '%s' is not supported, use one of '%s'", format, strings.Join(lintFormats, "', '"))
+}
+
+// LintFails returns true if any of the findings is at least as severe as
+// failOn, which is either 'warning', 'error' or 'never'.
+func LintFails(findings []LintFinding, failOn string) (bool, error) {
+	switch failOn {
+	case "never":
+		return false, nil
+	case lintSeverityWarning:
+		return len(findings) > 0, nil
+	case lintSeverityError:
+		for _, f := range findings {
+			if f.Severity == lintSeverityError {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return false, fmt.Errorf("'%s' is not a valid severity to fail on, use one of 'warning', 'error' or 'never'", failOn)
+}
+
+// PrintLintRules lists all rules with their severity as configured for the
+// repository.
+func PrintLintRules(w io.Writer, cfg LintConfig) {
+	for _, r := range lintRules {
+		fmt.Fprintf(w, "%-22s %-8s %s\n", r.ID, cfg.severity(r, ""), r.Description)
+	}
+}
+
+func writeLintText(w io.Writer, findings []LintFinding) {
+	grouped := map[string][]string{}
+	for _, f := range findings {
+		grouped[f.Rule] = append(grouped[f.Rule], f.Message)
+	}
+	for _, r := range lintRules {
+		if len(grouped[r.ID]) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%s:\n", r.Category)
+		for _, m := range grouped[r.ID] {
+			fmt.Fprintf(w, "   %s\n", m)
+		}
+	}
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string            `json:"id"`
+	ShortDescription     sarifMessage      `json:"shortDescription"`
+	DefaultConfiguration map[string]string `json:"defaultConfiguration"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation map[string]string `json:"artifactLocation"`
+	Region           map[string]int    `json:"region,omitempty"`
+}
+
+func writeLintSARIF(w io.Writer, findings []LintFinding) error {
+	driver := sarifDriver{
+		Name:           "solaris",
+		Version:        version,
+		InformationURI: "https://github.com/unprofession-al/solaris",
+		Rules:          []sarifRule{},
+	}
+	for _, r := range lintRules {
+		driver.Rules = append(driver.Rules, sarifRule{
+			ID:                   r.ID,
+			ShortDescription:     sarifMessage{Text: r.Description},
+			DefaultConfiguration: map[string]string{"level": r.Severity},
+		})
+	}
+
+	results := []sarifResult{}
+	for _, f := range findings {
+		result := sarifResult{
+			RuleID:  f.Rule,
+			Level:   f.Severity,
+			Message: sarifMessage{Text: f.Message},
+		}
+		if f.File != "" {
+			location := sarifPhysicalLocation{ArtifactLocation: map[string]string{"uri": f.File}}
+			if f.Line > 0 {
+				location.Region = map[string]int{"startLine": f.Line}
+			}
+			result.Locations = []sarifLocation{{PhysicalLocation: location}}
+		}
+		results = append(results, result)
+	}
+
+	return writeJSON(w, sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	})
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeLintJUnit writes one test suite per rule with one failed test case per
+// finding. Rules without findings are reported as a single passed test case.
+func writeLintJUnit(w io.Writer, findings []LintFinding) error {
+	suites := junitTestSuites{}
+	for _, r := range lintRules {
+		suite := junitTestSuite{Name: "solaris." + r.ID, Cases: []junitTestCase{}}
+		for _, f := range findings {
+			if f.Rule != r.ID {
+				continue
+			}
+			suite.Cases = append(suite.Cases, junitTestCase{
+				Name:      lintLocation(f),
+				ClassName: suite.Name,
+				Failure:   &junitFailure{Message: f.Message, Type: f.Severity, Text: f.Message},
+			})
+		}
+		suite.Failures = len(suite.Cases)
+		if suite.Failures == 0 {
+			suite.Cases = append(suite.Cases, junitTestCase{Name: r.ID, ClassName: suite.Name})
+		}
+		suite.Tests = len(suite.Cases)
+		suites.Suites = append(suites.Suites, suite)
+	}
+	return writeXML(w, suites)
+}
+
+type checkstyleResult struct {
+	XMLName xml.Name         `xml:"checkstyle"`
+	Version string           `xml:"version,attr"`
+	Files   []checkstyleFile `xml:"file"`
+}
+
+type checkstyleFile struct {
+	Name   string            `xml:"name,attr"`
+	Errors []checkstyleError `xml:"error"`
+}
+
+type checkstyleError struct {
+	Line     int    `xml:"line,attr,omitempty"`
+	Severity string `xml:"severity,attr"`
+	Message  string `xml:"message,attr"`
+	Source   string `xml:"source,attr"`
+}
+
+func writeLintCheckstyle(w io.Writer, findings []LintFinding) error {
+	result := checkstyleResult{Version: "4.3", Files: []checkstyleFile{}}
+	index := map[string]int{}
+	for _, f := range findings {
+		name := f.File
+		if name == "" {
+			name = f.Workspace
+		}
+		i, ok := index[name]
+		if !ok {
+			i = len(result.Files)
+			index[name] = i
+			result.Files = append(result.Files, checkstyleFile{Name: name})
+		}
+		result.Files[i].Errors = append(result.Files[i].Errors, checkstyleError{
+			Line:     f.Line,
+			Severity: f.Severity,
+			Message:  f.Message,
+			Source:   "solaris." + f.Rule,
+		})
+	}
+	return writeXML(w, result)
+}
+
+// writeLintGitHub writes workflow commands creating annotations in GitHub
+// Actions.
+func writeLintGitHub(w io.Writer, findings []LintFinding) {
+	for _, f := range findings {
+		props := []string{}
+		if f.File != "" {
+			props = append(props, "file="+githubEscapeProperty(f.File))
+			if f.Line > 0 {
+				props = append(props, fmt.Sprintf("line=%d", f.Line))
+			}
+		}
+		props = append(props, "title="+githubEscapeProperty(f.Rule))
+		fmt.Fprintf(w, "::%s %s::%s\n", f.Severity, strings.Join(props, ","), githubEscapeData(f.Message))
+	}
+}
+
+func githubEscapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+func githubEscapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+// lintLocation returns 'file:line' of a finding or the workspace if the file
+// is unknown.
+func lintLocation(f LintFinding) string {
+	switch {
+	case f.File != "" && f.Line > 0:
+		return fmt.Sprintf("%s:%d", f.File, f.Line)
+	case f.File != "":
+		return f.File
+	}
+	return f.Workspace
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "    ")
+	return enc.Encode(v)
+}
+
+func writeXML(w io.Writer, v interface{}) error {
+	out, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s%s\n", xml.Header, out)
+	return err
+}
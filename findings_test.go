@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+var testFindings = []LintFinding{
+	{Rule: lintRuleUnusedOutput, Severity: lintSeverityWarning, Workspace: "net/", File: "net/main.tf", Line: 14, Subject: "spare", Message: "output 'spare' seems to be unused"},
+	{Rule: lintRuleMissingBackend, Severity: lintSeverityError, Workspace: "api/", Subject: "api/", Message: "workspace 'api/' has no backend, a: b"},
+}
+
+func TestWriteLintFindings(t *testing.T) {
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{LintFormatText, []string{"Usused Outputs:\n   output 'spare' seems to be unused\n", "Missing Backends:\n   workspace 'api/' has no backend, a: b\n"}},
+		{LintFormatJSON, []string{`"rule": "unused-output"`, `"line": 14`}},
+		{LintFormatSARIF, []string{`"ruleId": "missing-backend"`, `"uri": "net/main.tf"`, `"startLine": 14`}},
+		{LintFormatJUnit, []string{`<testsuite name="solaris.unused-output" tests="1" failures="1">`, `<testcase name="net/main.tf:14" classname="solaris.unused-output">`, `<testcase name="api/" classname="solaris.missing-backend">`}},
+		{LintFormatCheckstyle, []string{`<file name="net/main.tf">`, `<error line="14" severity="warning"`, `<file name="api/">`}},
+		{LintFormatGitHub, []string{"::warning file=net/main.tf,line=14,title=unused-output::output 'spare' seems to be unused\n", "::error title=missing-backend::workspace 'api/' has no backend, a: b\n"}},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if err := WriteLintFindings(&out, testFindings, tt.format); err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("%s output does not contain %s:\n%s", tt.format, want, out.String())
+			}
+		}
+		switch tt.format {
+		case LintFormatJSON, LintFormatSARIF:
+			var v interface{}
+			if err := json.Unmarshal(out.Bytes(), &v); err != nil {
+				t.Errorf("%s output is invalid: %s", tt.format, err)
+			}
+		case LintFormatJUnit, LintFormatCheckstyle:
+			var v struct{}
+			if err := xml.Unmarshal(out.Bytes(), &v); err != nil {
+				t.Errorf("%s output is invalid: %s", tt.format, err)
+			}
+		}
+	}
+
+	if err := WriteLintFindings(&bytes.Buffer{}, testFindings, "yaml"); err == nil {
+		t.Errorf("expected an error for an unsupported format")
+	}
+}
+
+func TestLintFails(t *testing.T) {
+	warnings := testFindings[:1]
+	tests := []struct {
+		findings []LintFinding
+		failOn   string
+		fails    bool
+		err      bool
+	}{
+		{testFindings, lintSeverityError, true, false},
+		{warnings, lintSeverityError, false, false},
+		{warnings, lintSeverityWarning, true, false},
+		{[]LintFinding{}, lintSeverityWarning, false, false},
+		{testFindings, "never", false, false},
+		{testFindings, "info", false, true},
+	}
+	for _, tt := range tests {
+		fails, err := LintFails(tt.findings, tt.failOn)
+		if (err != nil) != tt.err {
+			t.Errorf("failing on '%s' returned error %v", tt.failOn, err)
+		}
+		if fails != tt.fails {
+			t.Errorf("%d findings failing on '%s' fail %t, want %t", len(tt.findings), tt.failOn, fails, tt.fails)
+		}
+	}
+}
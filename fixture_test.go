@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// tfBackend returns a terraform block storing the state under key.
+func tfBackend(key string) string {
+	return fmt.Sprintf(`terraform {
+  backend "s3" {
+    bucket  = "state"
+    key     = "%s"
+    profile = "prod"
+    region  = "eu-west-1"
+  }
+}
+`, key)
+}
+
+// tfRemoteState returns a terraform_remote_state data source reading the
+// state stored under key.
+func tfRemoteState(name, key string) string {
+	return fmt.Sprintf(`
+data "terraform_remote_state" "%s" {
+  backend = "s3"
+  config {
+    bucket  = "state"
+    key     = "%s"
+    profile = "prod"
+    region  = "eu-west-1"
+  }
+}
+`, name, key)
+}
+
+func tfOutput(name, value string) string {
+	return fmt.Sprintf("\noutput \"%s\" {\n  value = %s\n}\n", name, value)
+}
+
+// writeFixture writes files given by their slash separated path relative to
+// dir.
+func writeFixture(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// tempFixture writes files to a new temporary directory and returns its path
+// and a function removing it.
+func tempFixture(t *testing.T, files map[string]string) (string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "solaris")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// git reports resolved paths
+	dir, err = filepath.EvalSymlinks(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFixture(t, dir, files)
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+// gitFixture works like tempFixture but commits the files to a new git
+// repository.
+func gitFixture(t *testing.T, files map[string]string) (string, func()) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, cleanup := tempFixture(t, files)
+	runGit(t, dir, "init", "-q")
+	runGit(t, dir, "config", "user.email", "solaris@example.com")
+	runGit(t, dir, "config", "user.name", "solaris")
+	runGit(t, dir, "add", "-A")
+	runGit(t, dir, "commit", "-q", "-m", "init")
+	return dir, cleanup
+}
+
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	if _, err := git(dir, args...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// chdir changes the working directory and returns a function changing it back.
+func chdir(t *testing.T, dir string) func() {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	return func() { os.Chdir(wd) }
+}
+
+func workspaceSlice(workspaces map[string]*Workspace) []*Workspace {
+	out := []*Workspace{}
+	for _, ws := range workspaces {
+		out = append(out, ws)
+	}
+	return out
+}
+
+func workspaceRoots(workspaces []*Workspace) []string {
+	roots := []string{}
+	for _, ws := range workspaces {
+		roots = append(roots, ws.Root)
+	}
+	sort.Strings(roots)
+	return roots
+}
+
+// planRoots returns the sorted roots of each tier, joined by spaces.
+func planRoots(plan [][]*Workspace) []string {
+	out := []string{}
+	for _, tier := range plan {
+		out = append(out, strings.Join(workspaceRoots(tier), " "))
+	}
+	return out
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
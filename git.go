@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+func git(dir string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return out, fmt.Errorf("could not run command 'git %s' in '%s': %s %s", strings.Join(args, " "), dir, err.Error(), strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+func gitLines(out []byte) []string {
+	lines := []string{}
+	for _, line := range strings.Split(string(out), "\n") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// gitTopLevel returns the absolute path of the git repository containing dir
+// with all symlinks resolved.
+func gitTopLevel(dir string) (string, error) {
+	out, err := git(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(filepath.FromSlash(strings.TrimSpace(string(out))))
+}
+
+// resolvePath returns the absolute path of path with all symlinks resolved.
+// Paths that do not exist in the working tree are resolved up to their
+// closest existing parent.
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err == nil || !os.IsNotExist(err) || abs == filepath.Dir(abs) {
+		return resolved, err
+	}
+	parent, err := resolvePath(filepath.Dir(abs))
+	return filepath.Join(parent, filepath.Base(abs)), err
+}
+
+// gitChangedFiles returns the absolute paths of all files in the repository
+// containing dir that differ between ref and the working tree, including
+// untracked files. Both paths of renamed files are returned.
+func gitChangedFiles(dir, ref string) ([]string, error) {
+	files := []string{}
+
+	top, err := gitTopLevel(dir)
+	if err != nil {
+		return files, err
+	}
+
+	changed, err := git(top, "diff", "--name-only", "--no-renames", ref, "--")
+	if err != nil {
+		return files, err
+	}
+	untracked, err := git(top, "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return files, err
+	}
+
+	for _, f := range append(gitLines(changed), gitLines(untracked)...) {
+		files = append(files, filepath.Join(top, filepath.FromSlash(f)))
+	}
+	return files, nil
+}
+
+// gitShowFile returns the content of the file at path as of ref. The boolean
+// returned is false if the file did not exist at ref.
+func gitShowFile(path, ref string) ([]byte, bool, error) {
+	abs, err := resolvePath(path)
+	if err != nil {
+		return nil, false, err
+	}
+	top, err := gitTopLevel(filepath.Dir(abs))
+	if err != nil {
+		return nil, false, err
+	}
+	rel, err := filepath.Rel(top, abs)
+	if err != nil {
+		return nil, false, err
+	}
+	rel = filepath.ToSlash(rel)
+
+	found, err := git(top, "ls-tree", "--name-only", ref, "--", rel)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(gitLines(found)) == 0 {
+		return nil, false, nil
+	}
+
+	out, err := git(top, "show", ref+":"+rel)
+	return out, true, err
+}
+
+// gitListDir returns the names of the files directly in dir as of ref.
+func gitListDir(dir, ref string) ([]string, error) {
+	names := []string{}
+	abs, err := resolvePath(dir)
+	if err != nil {
+		return names, err
+	}
+	top, err := gitTopLevel(abs)
+	if err != nil {
+		return names, err
+	}
+	rel, err := filepath.Rel(top, abs)
+	if err != nil {
+		return names, err
+	}
+
+	out, err := git(top, "ls-tree", "--name-only", ref, "--", filepath.ToSlash(rel)+"/")
+	if err != nil {
+		return names, err
+	}
+	for _, f := range gitLines(out) {
+		names = append(names, path.Base(f))
+	}
+	return names, nil
+}
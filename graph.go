@@ -1,70 +1,505 @@
 package main
 
 import (
+	"fmt"
+	"io"
+	"sort"
 	"strings"
+)
+
+const (
+	nodeKindWorkspace = "workspace"
+	nodeKindOutput    = "output"
+	nodeKindInput     = "input"
+)
 
-	"github.com/emicklei/dot"
+// shapes of workspace nodes depending on the manuals they carry
+const (
+	nodeShapePreManual  = "pre_manual"
+	nodeShapePostManual = "post_manual"
+	nodeShapeManual     = "manual"
 )
 
-func RenderWorkspacesDetailed(workspaces map[string]*Workspace) *dot.Graph {
-	g := dot.NewGraph()
+// tierColors are used to fill workspace nodes by their tier in the execution
+// plan, they are repeated for plans with more tiers.
+var tierColors = []string{"#dbe9f6", "#dcf0dc", "#fdf0d5", "#f3dcef", "#dff3f3", "#eeeeda"}
+
+// Graph is the format independent representation of workspace dependencies
+// passed to GraphExporters. In simple graphs all nodes are workspaces and
+// edges point from consumers to producers. In detailed graphs workspaces
+// contain their outputs and inputs and edges point from inputs to outputs.
+type Graph struct {
+	Detailed bool         `json:"detailed"`
+	Nodes    []*GraphNode `json:"nodes"`
+	Edges    []*GraphEdge `json:"edges"`
+}
+
+type GraphNode struct {
+	ID        string            `json:"id"`
+	Label     string            `json:"label"`
+	Kind      string            `json:"kind"`
+	Parent    string            `json:"parent,omitempty"`
+	Workspace string            `json:"workspace"`
+	Color     string            `json:"color,omitempty"`
+	Fill      string            `json:"fill,omitempty"`
+	Shape     string            `json:"shape,omitempty"`
+	Tier      int               `json:"tier,omitempty"`
+	Group     string            `json:"group,omitempty"`
+	Data      map[string]string `json:"data"`
+}
+
+type GraphEdge struct {
+	ID    string            `json:"id"`
+	From  string            `json:"from"`
+	To    string            `json:"to"`
+	Label string            `json:"label,omitempty"`
+	Color string            `json:"color,omitempty"`
+	Data  map[string]string `json:"data"`
+}
+
+// BuildGraph returns the graph of the workspaces given, nodes and edges are
+// sorted to produce stable output.
+func BuildGraph(workspaces map[string]*Workspace, detailed bool) *Graph {
+	g := &Graph{
+		Detailed: detailed,
+		Nodes:    []*GraphNode{},
+		Edges:    []*GraphEdge{},
+	}
+	names := sortedWorkspaceNames(workspaces)
+	tiers := workspaceTiers(workspaces)
 
 	// draw workspaces
-	for name, workspace := range workspaces {
-		// draw workspace
-		workspace.graphElement = g.Subgraph(name, dot.ClusterOption{})
+	for _, name := range names {
+		workspace := workspaces[name]
+		node := &GraphNode{
+			ID:        name,
+			Label:     name,
+			Kind:      nodeKindWorkspace,
+			Workspace: name,
+			Shape:     manualShape(workspace),
+			Tier:      tiers[workspace],
+			Group:     workspace.Owner,
+			Data:      workspaceData(workspace),
+		}
+		if node.Tier > 0 {
+			node.Fill = tierColors[(node.Tier-1)%len(tierColors)]
+			node.Data["tier"] = fmt.Sprintf("%d", node.Tier)
+		}
+		g.Nodes = append(g.Nodes, node)
+		if !detailed {
+			continue
+		}
 
 		// draw outputs
-		if len(workspace.Outputs) > 0 {
-			outputs := workspace.graphElement.Subgraph("outputs", dot.ClusterOption{})
-			for i, output := range workspace.Outputs {
-				workspace.Outputs[i].graphElement = outputs.Node(output.Name)
-			}
+		for _, output := range workspace.Outputs {
+			g.Nodes = append(g.Nodes, &GraphNode{
+				ID:        outputNodeID(&output),
+				Label:     output.Name,
+				Kind:      nodeKindOutput,
+				Parent:    name,
+				Workspace: name,
+				Data: map[string]string{
+					"name":      output.Name,
+					"file":      output.InFile,
+					"consumers": fmt.Sprintf("%d", len(output.ReferedBy)),
+				},
+			})
 		}
 
 		// draw inputs
-		if len(workspace.Inputs) > 0 {
-			inputs := workspace.graphElement.Subgraph("inputs", dot.ClusterOption{})
-			for i, input := range workspace.Inputs {
-				workspace.Inputs[i].graphElement = inputs.Node(input.Name)
+		for i, input := range workspace.Inputs {
+			node := &GraphNode{
+				ID:        inputNodeID(name, i),
+				Label:     input.Name,
+				Kind:      nodeKindInput,
+				Parent:    name,
+				Workspace: name,
+				Data: map[string]string{
+					"name":      input.Name,
+					"full_name": input.FullName,
+					"files":     strings.Join(input.InFile, ", "),
+				},
+			}
+			if input.ReferesTo == nil {
+				node.Color = "red"
+			} else {
+				node.Data["refers_to"] = outputNodeID(input.ReferesTo)
 			}
+			g.Nodes = append(g.Nodes, node)
 		}
 	}
 
 	// draw relations/dependencies
-	for _, workspace := range workspaces {
-		for i, input := range workspace.Inputs {
-			if input.ReferesTo != nil {
-				g.Edge(input.graphElement, input.ReferesTo.graphElement).Attr("label", strings.Join(input.InFile, ", "))
-			} else {
-				workspace.Inputs[i].graphElement.Attr("color", "red")
+	if detailed {
+		for _, name := range names {
+			for i, input := range workspaces[name].Inputs {
+				if input.ReferesTo == nil {
+					continue
+				}
+				g.Edges = append(g.Edges, &GraphEdge{
+					ID:    fmt.Sprintf("e%d", len(g.Edges)),
+					From:  inputNodeID(name, i),
+					To:    outputNodeID(input.ReferesTo),
+					Label: strings.Join(input.InFile, ", "),
+					Data: map[string]string{
+						"files": strings.Join(input.InFile, ", "),
+					},
+				})
 			}
 		}
+		return g
 	}
 
+	index := map[string]int{}
+	dataSources, files := [][]string{}, [][]string{}
+	for _, name := range names {
+		for _, dep := range workspaces[name].Dependencies {
+			for _, otherName := range names {
+				if !dep.equals(workspaces[otherName].RemoteState) {
+					continue
+				}
+				key := name + "\x00" + otherName
+				i, ok := index[key]
+				if !ok {
+					i = len(g.Edges)
+					index[key] = i
+					g.Edges = append(g.Edges, &GraphEdge{
+						ID:   fmt.Sprintf("e%d", i),
+						From: name,
+						To:   otherName,
+						Data: map[string]string{},
+					})
+					dataSources = append(dataSources, []string{})
+					files = append(files, []string{})
+				}
+				dataSources[i] = uniqueStrings(append(dataSources[i], dep.Name))
+				files[i] = uniqueStrings(append(files[i], dep.InFile))
+			}
+		}
+	}
+	outputs := map[string][]string{}
+	for _, name := range names {
+		for _, input := range workspaces[name].Inputs {
+			if input.ReferesTo != nil {
+				key := name + "\x00" + input.ReferesTo.BelongsTo.Root
+				outputs[key] = uniqueStrings(append(outputs[key], input.Name))
+			}
+		}
+	}
+	for key, i := range index {
+		e := g.Edges[i]
+		e.Data["data_sources"] = strings.Join(dataSources[i], ", ")
+		e.Data["files"] = strings.Join(files[i], ", ")
+		e.Data["outputs"] = strings.Join(outputs[key], ", ")
+		e.Label = e.Data["outputs"]
+	}
 	return g
 }
 
-func RenderWorkspaces(workspaces map[string]*Workspace) *dot.Graph {
-	g := dot.NewGraph(dot.Directed)
+// workspaceTiers returns the tier of each workspace in the execution plan of
+// all workspaces, starting at 1. Workspaces that cannot be planned, e.g. due
+// to circular dependencies, are omitted.
+func workspaceTiers(workspaces map[string]*Workspace) map[*Workspace]int {
+	tiers := map[*Workspace]int{}
+	wsarray := []*Workspace{}
+	for _, ws := range workspaces {
+		wsarray = append(wsarray, ws)
+	}
+	// the tiers planned before a circular dependency are still valid
+	plan, _ := BuildExecutionPlan(wsarray, []string{}, func(string) {})
+	for i, tier := range plan {
+		for _, ws := range tier {
+			tiers[ws] = i + 1
+		}
+	}
+	return tiers
+}
 
-	nodes := map[string]dot.Node{}
+func manualShape(ws *Workspace) string {
+	switch {
+	case ws.PreManual != "" && ws.PostManual != "":
+		return nodeShapeManual
+	case ws.PreManual != "":
+		return nodeShapePreManual
+	case ws.PostManual != "":
+		return nodeShapePostManual
+	}
+	return ""
+}
 
-	// draw workspaces
-	for name := range workspaces {
-		nodes[name] = g.Node(name)
+// AnnotateLint adds the messages of all lint findings to the data of the
+// workspace nodes they belong to. Workspaces with errors such as dangling
+// inputs or circular dependencies as well as the edges forming cycles are
+// coloured red.
+func (g *Graph) AnnotateLint(findings []LintFinding) {
+	messages := map[string][]string{}
+	broken := map[string]bool{}
+	for _, f := range findings {
+		messages[f.Workspace] = append(messages[f.Workspace], f.Message)
+		if f.Severity == lintSeverityError {
+			broken[f.Workspace] = true
+		}
+	}
+	for _, n := range g.roots() {
+		if m, ok := messages[n.ID]; ok {
+			sort.Strings(m)
+			n.Data["lint"] = strings.Join(m, "\n")
+		}
+		if broken[n.ID] {
+			n.Color = "red"
+		}
 	}
 
-	// draw relations/dependencies
-	for name, workspace := range workspaces {
-		for _, dep := range workspace.Dependencies {
-			for otherName, other := range workspaces {
-				if dep.equals(other.RemoteState) {
-					g.Edge(nodes[name], nodes[otherName])
+	cycles := g.cycles()
+	workspace := map[string]string{}
+	for _, n := range g.Nodes {
+		workspace[n.ID] = n.Workspace
+	}
+	for _, e := range g.Edges {
+		from, to := workspace[e.From], workspace[e.To]
+		if from != to && cycles[from] != 0 && cycles[from] == cycles[to] {
+			e.Color = "red"
+		}
+	}
+}
+
+// cycles returns the workspaces being part of a circular dependency mapped to
+// a number identifying the cycle, i.e. the strongly connected component.
+func (g *Graph) cycles() map[string]int {
+	next := map[string][]string{}
+	for _, e := range g.workspaceEdges() {
+		next[e.From] = append(next[e.From], e.To)
+	}
+
+	// tarjan's algorithm
+	index, low := map[string]int{}, map[string]int{}
+	onStack := map[string]bool{}
+	stack := []string{}
+	out := map[string]int{}
+	components := 0
+	var connect func(string)
+	connect = func(v string) {
+		index[v] = len(index)
+		low[v] = index[v]
+		stack = append(stack, v)
+		onStack[v] = true
+		for _, w := range next[v] {
+			if _, ok := index[w]; !ok {
+				connect(w)
+				if low[w] < low[v] {
+					low[v] = low[w]
 				}
+			} else if onStack[w] && index[w] < low[v] {
+				low[v] = index[w]
+			}
+		}
+		if low[v] != index[v] {
+			return
+		}
+		component := []string{}
+		for {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[w] = false
+			component = append(component, w)
+			if w == v {
+				break
+			}
+		}
+		if len(component) > 1 {
+			components++
+			for _, w := range component {
+				out[w] = components
 			}
 		}
 	}
+	for _, n := range g.roots() {
+		if _, ok := index[n.ID]; !ok {
+			connect(n.ID)
+		}
+	}
+	return out
+}
 
-	return g
+// workspaceEdges returns the edges of the graph between workspace nodes. The
+// edges of detailed graphs are mapped to the workspaces of their nodes and
+// merged, listing the outputs they carry.
+func (g *Graph) workspaceEdges() []*GraphEdge {
+	if !g.Detailed {
+		return g.Edges
+	}
+
+	nodes := map[string]*GraphNode{}
+	for _, n := range g.Nodes {
+		nodes[n.ID] = n
+	}
+
+	edges := []*GraphEdge{}
+	index := map[string]*GraphEdge{}
+	for _, e := range g.Edges {
+		from, to := nodes[e.From], nodes[e.To]
+		if from == nil || to == nil || from.Workspace == to.Workspace {
+			continue
+		}
+		key := from.Workspace + "\x00" + to.Workspace
+		we, ok := index[key]
+		if !ok {
+			we = &GraphEdge{
+				ID:   fmt.Sprintf("w%d", len(edges)),
+				From: from.Workspace,
+				To:   to.Workspace,
+				Data: map[string]string{},
+			}
+			index[key] = we
+			edges = append(edges, we)
+		}
+		outputs := []string{}
+		if we.Data["outputs"] != "" {
+			outputs = strings.Split(we.Data["outputs"], ", ")
+		}
+		we.Data["outputs"] = strings.Join(uniqueStrings(append(outputs, to.Label)), ", ")
+		if e.Color != "" {
+			we.Color = e.Color
+		}
+	}
+	return edges
+}
+
+// children returns the nodes whose parent is the node with the id given.
+func (g *Graph) children(id string) []*GraphNode {
+	out := []*GraphNode{}
+	for _, n := range g.Nodes {
+		if n.Parent == id {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// roots returns all nodes without parent.
+func (g *Graph) roots() []*GraphNode {
+	return g.children("")
+}
+
+func workspaceData(ws *Workspace) map[string]string {
+	files := []string{}
+	for name := range ws.Files {
+		files = append(files, name)
+	}
+	sort.Strings(files)
+
+	return map[string]string{
+		"root":        ws.Root,
+		"files":       strings.Join(files, ", "),
+		"bucket":      ws.RemoteState.Bucket,
+		"key":         ws.RemoteState.Key,
+		"profile":     ws.RemoteState.Profile,
+		"region":      ws.RemoteState.Region,
+		"pre_manual":  fmt.Sprintf("%t", ws.PreManual != ""),
+		"post_manual": fmt.Sprintf("%t", ws.PostManual != ""),
+	}
+}
+
+func outputNodeID(output *Output) string {
+	return output.BelongsTo.Root + "#output:" + output.Name
+}
+
+func inputNodeID(name string, i int) string {
+	return fmt.Sprintf("%s#input:%d", name, i)
+}
+
+func sortedGroupNames(groups map[string][]*GraphNode) []string {
+	names := []string{}
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedWorkspaceNames(workspaces map[string]*Workspace) []string {
+	names := []string{}
+	for name := range workspaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GraphExporter writes a graph in a specific format.
+type GraphExporter interface {
+	Export(w io.Writer, g *Graph) error
+}
+
+var graphExporters = map[string]GraphExporter{
+	"dot":       dotExporter{},
+	"mermaid":   mermaidExporter{},
+	"plantuml":  plantUMLExporter{},
+	"graphml":   graphMLExporter{},
+	"cytoscape": cytoscapeExporter{},
+	"html":      htmlExporter{},
+	"svg":       svgExporter{},
+}
+
+// GetGraphExporter returns the exporter for the format given.
+func GetGraphExporter(format string) (GraphExporter, error) {
+	e, ok := graphExporters[format]
+	if format == "png" {
+		return nil, fmt.Errorf("graph format 'png' is not supported, use 'svg' and convert the image if a raster image is required")
+	}
+	if !ok {
+		return nil, fmt.Errorf("graph format '%s' is not supported, use one of '%s'", format, strings.Join(GraphFormats(), "', '"))
+	}
+	return e, nil
+}
+
+// GraphFormats returns the names of all supported graph formats, sorted.
+func GraphFormats() []string {
+	formats := []string{}
+	for format := range graphExporters {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// dataSummary returns the data of a node or edge as one 'key: value' line per
+// entry, sorted by key. Empty values are omitted.
+func dataSummary(data map[string]string) string {
+	keys := []string{}
+	for k, v := range data {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	lines := []string{}
+	for _, k := range keys {
+		lines = append(lines, k+": "+data[k])
+	}
+	return strings.Join(lines, "\n")
+}
+
+// nodeIDs returns an identifier per node of the graph derived by the function
+// given. Nodes whose identifiers collide are told apart by a numeric suffix in
+// the order of their ids.
+func (g *Graph) nodeIDs(id func(string) string) map[string]string {
+	nodes := []string{}
+	for _, n := range g.Nodes {
+		nodes = append(nodes, n.ID)
+	}
+	sort.Strings(nodes)
+
+	ids := map[string]string{}
+	taken := map[string]bool{}
+	for _, n := range nodes {
+		base := id(n)
+		out := base
+		for i := 2; taken[out]; i++ {
+			out = fmt.Sprintf("%s_%d", base, i)
+		}
+		taken[out] = true
+		ids[n] = out
+	}
+	return ids
 }
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// graphIDs returns the node identifiers found by the pattern given in the
+// output of the exporter.
+func graphIDs(t *testing.T, format string, g *Graph, pattern string) []string {
+	var out bytes.Buffer
+	if err := graphExporters[format].Export(&out, g); err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, m := range regexp.MustCompile(pattern).FindAllStringSubmatch(out.String(), -1) {
+		ids = append(ids, m[1])
+	}
+	return ids
+}
+
+func TestGraphExportUniqueIDs(t *testing.T) {
+	files := map[string]string{
+		"apps/api/main.tf": tfBackend("apps/api"),
+		"apps-api/main.tf": tfBackend("apps-api") + tfRemoteState("api", "apps/api"),
+		"apps_api/main.tf": tfBackend("apps_api"),
+	}
+	dir, cleanup := tempFixture(t, files)
+	defer cleanup()
+	defer chdir(t, dir)()
+
+	workspaces, err := GetWorkspaces(".", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := BuildGraph(workspaces, false)
+
+	tests := []struct {
+		format string
+		nodes  string
+		edges  string
+		ids    []string
+		edge   []string
+	}{
+		{"mermaid", `(?m)^    (\w+)\["`, `(?m)^    (\w+ --> \w+)$`, []string{"ws_apps_api", "ws_apps_api_2", "ws_apps_api_3"}, []string{"ws_apps_api --> ws_apps_api_2"}},
+		{"plantuml", `(?m)^rectangle "[^"]*" as (\w+)`, `(?m)^(\w+ --> \w+)$`, []string{"apps_api", "apps_api_2", "apps_api_3"}, []string{"apps_api --> apps_api_2"}},
+	}
+	for _, tt := range tests {
+		if got := graphIDs(t, tt.format, g, tt.nodes); !equalStrings(got, tt.ids) {
+			t.Errorf("%s nodes are %q, want %q", tt.format, got, tt.ids)
+		}
+		if got := graphIDs(t, tt.format, g, tt.edges); !equalStrings(got, tt.edge) {
+			t.Errorf("%s edges are %q, want %q", tt.format, got, tt.edge)
+		}
+	}
+}
+
+func TestDotGraphType(t *testing.T) {
+	files := map[string]string{
+		"net/main.tf": tfBackend("net") + tfOutput("vpc_id", `"vpc"`),
+		"api/main.tf": tfBackend("api") + tfRemoteState("net", "net") + tfOutput("url", "data.terraform_remote_state.net.outputs.vpc_id"),
+	}
+	dir, cleanup := tempFixture(t, files)
+	defer cleanup()
+	defer chdir(t, dir)()
+
+	workspaces, err := GetWorkspaces(".", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		detailed bool
+		header   string
+		edge     string
+	}{
+		{false, "digraph ", `(?m)^\s*n\d+->n\d+`},
+		{true, "graph ", `(?m)^\s*n\d+--n\d+`},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if err := graphExporters["dot"].Export(&out, BuildGraph(workspaces, tt.detailed)); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(out.String(), tt.header) {
+			t.Errorf("detailed %t graph does not start with '%s':\n%s", tt.detailed, tt.header, out.String())
+		}
+		if !regexp.MustCompile(tt.edge).MatchString(out.String()) {
+			t.Errorf("detailed %t graph has no edge matching %s:\n%s", tt.detailed, tt.edge, out.String())
+		}
+	}
+}
+
+func TestWorkspaceTiers(t *testing.T) {
+	files := map[string]string{
+		"net/main.tf": tfBackend("net") + tfOutput("vpc_id", `"vpc"`),
+		"api/main.tf": tfBackend("api") + tfRemoteState("net", "net") + tfOutput("url", "data.terraform_remote_state.net.outputs.vpc_id"),
+		"a/main.tf":   tfBackend("a") + tfRemoteState("b", "b") + tfOutput("x", "data.terraform_remote_state.b.outputs.y"),
+		"b/main.tf":   tfBackend("b") + tfRemoteState("a", "a") + tfOutput("y", "data.terraform_remote_state.a.outputs.x"),
+	}
+	dir, cleanup := tempFixture(t, files)
+	defer cleanup()
+	defer chdir(t, dir)()
+
+	workspaces, err := GetWorkspaces(".", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tiers := workspaceTiers(workspaces)
+	want := map[string]int{"net/": 1, "api/": 2, "a/": 0, "b/": 0}
+	for root, tier := range want {
+		if got := tiers[workspaces[root]]; got != tier {
+			t.Errorf("workspace '%s' is in tier %d, want %d", root, got, tier)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+type graphMLExporter struct{}
+
+// Export writes the graph as GraphML document. Outputs and inputs of detailed
+// graphs are nested within the graphs of their workspace, node and edge data
+// is declared as string attributes.
+func (graphMLExporter) Export(w io.Writer, g *Graph) error {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://graphml.graphdrawing.org/xmlns http://graphml.graphdrawing.org/xmlns/1.0/graphml.xsd">` + "\n")
+
+	// declare keys
+	nodeKeys := map[string]bool{"label": true, "kind": true, "color": true, "fill": true, "shape": true, "group": true}
+	for _, n := range g.Nodes {
+		for k := range n.Data {
+			nodeKeys[k] = true
+		}
+	}
+	edgeKeys := map[string]bool{"label": true, "color": true}
+	for _, e := range g.Edges {
+		for k := range e.Data {
+			edgeKeys[k] = true
+		}
+	}
+	for _, k := range sortedKeys(nodeKeys) {
+		fmt.Fprintf(&b, `  <key id="n_%s" for="node" attr.name="%s" attr.type="string"/>`+"\n", k, k)
+	}
+	for _, k := range sortedKeys(edgeKeys) {
+		fmt.Fprintf(&b, `  <key id="e_%s" for="edge" attr.name="%s" attr.type="string"/>`+"\n", k, k)
+	}
+
+	b.WriteString(`  <graph id="G" edgedefault="directed">` + "\n")
+
+	var node func(n *GraphNode, indent string)
+	node = func(n *GraphNode, indent string) {
+		fmt.Fprintf(&b, `%s<node id="%s">`+"\n", indent, xmlEscape(n.ID))
+		data := map[string]string{"label": n.Label, "kind": n.Kind, "color": n.Color, "fill": n.Fill, "shape": n.Shape, "group": n.Group}
+		for k, v := range n.Data {
+			data[k] = v
+		}
+		writeGraphMLData(&b, indent+"  ", "n_", data)
+		children := g.children(n.ID)
+		if len(children) > 0 {
+			fmt.Fprintf(&b, `%s  <graph id="%s:" edgedefault="directed">`+"\n", indent, xmlEscape(n.ID))
+			for _, child := range children {
+				node(child, indent+"    ")
+			}
+			fmt.Fprintf(&b, "%s  </graph>\n", indent)
+		}
+		fmt.Fprintf(&b, "%s</node>\n", indent)
+	}
+
+	// draw workspaces
+	for _, ws := range g.roots() {
+		node(ws, "    ")
+	}
+
+	// draw relations/dependencies
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, `    <edge id="%s" source="%s" target="%s">`+"\n", xmlEscape(e.ID), xmlEscape(e.From), xmlEscape(e.To))
+		data := map[string]string{"label": e.Label, "color": e.Color}
+		for k, v := range e.Data {
+			data[k] = v
+		}
+		writeGraphMLData(&b, "      ", "e_", data)
+		b.WriteString("    </edge>\n")
+	}
+
+	b.WriteString("  </graph>\n</graphml>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeGraphMLData(b *strings.Builder, indent, prefix string, data map[string]string) {
+	keys := []string{}
+	for k, v := range data {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(b, `%s<data key="%s%s">%s</data>`+"\n", indent, prefix, k, xmlEscape(data[k]))
+	}
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
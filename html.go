@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"io"
+)
+
+type htmlExporter struct{}
+
+type htmlNode struct {
+	ID         string   `json:"id"`
+	Label      string   `json:"label"`
+	Upstream   []string `json:"upstream"`
+	Downstream []string `json:"downstream"`
+}
+
+type htmlPage struct {
+	SVG   template.HTML
+	Data  template.JS
+	Count int
+}
+
+// Export writes the graph as a single self-contained HTML page embedding the
+// graph drawn as SVG, see graphSVG. The page supports zooming, panning,
+// searching and highlighting everything upstream and downstream of a
+// workspace.
+func (htmlExporter) Export(w io.Writer, g *Graph) error {
+	roots := g.roots()
+	nodes := map[string]*htmlNode{}
+	for _, n := range roots {
+		nodes[n.ID] = &htmlNode{ID: n.ID, Label: n.Label, Upstream: []string{}, Downstream: []string{}}
+	}
+	for _, e := range g.workspaceEdges() {
+		if nodes[e.From] == nil || nodes[e.To] == nil {
+			continue
+		}
+		nodes[e.From].Upstream = append(nodes[e.From].Upstream, e.To)
+		nodes[e.To].Downstream = append(nodes[e.To].Downstream, e.From)
+	}
+
+	data, err := json.Marshal(nodes)
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := template.New("graph").Parse(htmlGraphTemplate)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, htmlPage{
+		SVG:   template.HTML(graphSVG(g, "graph")),
+		Data:  template.JS(data),
+		Count: len(roots),
+	})
+}
+
+const htmlGraphTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Workspaces</title>
+<style>
+html, body { margin: 0; height: 100%; font-family: sans-serif; }
+header { position: fixed; top: 0; left: 0; right: 0; padding: 0.5em 1em; background: #fff; border-bottom: 1px solid #ddd; z-index: 1; }
+header input { width: 20em; }
+header span { color: #666; margin-left: 1em; }
+#graph { position: absolute; top: 3em; left: 0; width: 100%; height: calc(100% - 3em); cursor: grab; }
+#graph.dragging { cursor: grabbing; }
+.node { cursor: pointer; }
+.dim { opacity: 0.15; }
+.match rect { stroke-width: 3; }
+.selected rect { stroke-width: 3; stroke: #222; }
+.edge.active { stroke: #222; stroke-width: 2.5; }
+.edge-label { pointer-events: none; }
+</style>
+</head>
+<body>
+<header>
+<input id="search" type="search" placeholder="search workspaces" autofocus>
+<span>{{ .Count }} workspaces, click a workspace to highlight its upstream and downstream, click the background to reset</span>
+</header>
+{{ .SVG }}
+<script>
+(function() {
+  var nodes = {{ .Data }};
+  var svg = document.getElementById("graph");
+  var view = svg.viewBox.baseVal;
+  var initial = { x: view.x, y: view.y, width: view.width, height: view.height };
+
+  // zoom and pan
+  function toGraph(evt) {
+    var r = svg.getBoundingClientRect();
+    var scale = Math.max(view.width / r.width, view.height / r.height);
+    return {
+      x: view.x + (evt.clientX - r.left - (r.width - view.width / scale) / 2) * scale,
+      y: view.y + (evt.clientY - r.top - (r.height - view.height / scale) / 2) * scale,
+      scale: scale
+    };
+  }
+  svg.addEventListener("wheel", function(evt) {
+    evt.preventDefault();
+    var p = toGraph(evt);
+    var f = evt.deltaY < 0 ? 0.8 : 1.25;
+    view.x = p.x - (p.x - view.x) * f;
+    view.y = p.y - (p.y - view.y) * f;
+    view.width *= f;
+    view.height *= f;
+  });
+  var drag = null;
+  svg.addEventListener("mousedown", function(evt) {
+    drag = { x: evt.clientX, y: evt.clientY, moved: false, scale: toGraph(evt).scale };
+    svg.classList.add("dragging");
+  });
+  window.addEventListener("mousemove", function(evt) {
+    if (!drag) { return; }
+    view.x -= (evt.clientX - drag.x) * drag.scale;
+    view.y -= (evt.clientY - drag.y) * drag.scale;
+    drag.moved = drag.moved || evt.clientX !== drag.x || evt.clientY !== drag.y;
+    drag.x = evt.clientX;
+    drag.y = evt.clientY;
+  });
+  window.addEventListener("mouseup", function() {
+    svg.classList.remove("dragging");
+    setTimeout(function() { drag = null; }, 0);
+  });
+
+  // highlight upstream and downstream of a workspace
+  function reachable(id, key, seen) {
+    nodes[id][key].forEach(function(next) {
+      if (!seen[next]) {
+        seen[next] = true;
+        reachable(next, key, seen);
+      }
+    });
+    return seen;
+  }
+  function reset() {
+    svg.querySelectorAll(".dim, .selected, .active").forEach(function(el) {
+      el.classList.remove("dim", "selected", "active");
+    });
+  }
+  function select(id) {
+    reset();
+    var up = reachable(id, "upstream", {});
+    var down = reachable(id, "downstream", {});
+    var keep = {};
+    keep[id] = true;
+    Object.keys(up).concat(Object.keys(down)).forEach(function(k) { keep[k] = true; });
+    svg.querySelectorAll(".node").forEach(function(el) {
+      var nid = el.getAttribute("data-id");
+      if (!keep[nid]) { el.classList.add("dim"); }
+      if (nid === id) { el.classList.add("selected"); }
+    });
+    svg.querySelectorAll(".edge").forEach(function(el) {
+      var from = el.getAttribute("data-from"), to = el.getAttribute("data-to");
+      // edges point from consumers to producers
+      if ((from === id || up[from]) && up[to] || down[from] && (to === id || down[to])) {
+        el.classList.add("active");
+      } else {
+        el.classList.add("dim");
+      }
+    });
+  }
+  svg.addEventListener("click", function(evt) {
+    if (drag && drag.moved) { return; }
+    var el = evt.target.closest(".node");
+    if (el) {
+      select(el.getAttribute("data-id"));
+    } else {
+      reset();
+    }
+  });
+
+  // search
+  document.getElementById("search").addEventListener("input", function(evt) {
+    var q = evt.target.value.toLowerCase();
+    var first = null;
+    svg.querySelectorAll(".node").forEach(function(el) {
+      var match = q !== "" && el.getAttribute("data-id").toLowerCase().indexOf(q) >= 0;
+      el.classList.toggle("match", match);
+      el.classList.toggle("dim", q !== "" && !match);
+      if (match && !first) { first = el; }
+    });
+    if (first) {
+      var b = first.getBBox();
+      view.x = b.x + b.width / 2 - view.width / 2;
+      view.y = b.y + b.height / 2 - view.height / 2;
+    } else if (q === "") {
+      view.x = initial.x;
+      view.y = initial.y;
+      view.width = initial.width;
+      view.height = initial.height;
+    }
+  });
+})();
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestHTMLExport(t *testing.T) {
+	workspace := func(id string) *GraphNode {
+		return &GraphNode{ID: id, Label: id, Kind: nodeKindWorkspace, Workspace: id, Data: map[string]string{}}
+	}
+	output := func(id, ws, label string) *GraphNode {
+		return &GraphNode{ID: id, Label: label, Kind: nodeKindOutput, Parent: ws, Workspace: ws, Data: map[string]string{}}
+	}
+	input := func(id, ws string) *GraphNode {
+		return &GraphNode{ID: id, Label: "in", Kind: nodeKindInput, Parent: ws, Workspace: ws, Data: map[string]string{}}
+	}
+	root := `net&co <script>alert("x")</script>/`
+
+	tests := []struct {
+		name  string
+		graph *Graph
+	}{
+		{"workspaces", &Graph{
+			Nodes: []*GraphNode{workspace(root), workspace("web/")},
+			Edges: []*GraphEdge{{ID: "e0", From: "web/", To: root, Label: "<b>vpc</b>", Data: map[string]string{"outputs": "<b>vpc</b>"}}},
+		}},
+		{"detailed", &Graph{
+			Detailed: true,
+			Nodes:    []*GraphNode{workspace(root), output("o", root, "<b>vpc</b>"), workspace("web/"), input("i", "web/")},
+			Edges:    []*GraphEdge{{ID: "e0", From: "i", To: "o", Data: map[string]string{}}},
+		}},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if err := (htmlExporter{}).Export(&out, tt.graph); err != nil {
+			t.Fatal(err)
+		}
+		page := out.String()
+
+		for _, want := range []string{"<!DOCTYPE html>", `<svg id="graph"`, "2 workspaces", "net&amp;co &lt;script&gt;"} {
+			if !strings.Contains(page, want) {
+				t.Errorf("%s: page does not contain '%s'", tt.name, want)
+			}
+		}
+		for _, unwanted := range []string{`<script>alert`, "<b>vpc</b>"} {
+			if strings.Contains(page, unwanted) {
+				t.Errorf("%s: page contains '%s' unescaped", tt.name, unwanted)
+			}
+		}
+
+		m := regexp.MustCompile(`(?m)^\s*var nodes = (.*);$`).FindStringSubmatch(page)
+		if m == nil {
+			t.Fatalf("%s: page does not embed the nodes", tt.name)
+		}
+		nodes := map[string]struct {
+			Label      string   `json:"label"`
+			Upstream   []string `json:"upstream"`
+			Downstream []string `json:"downstream"`
+		}{}
+		if err := json.Unmarshal([]byte(m[1]), &nodes); err != nil {
+			t.Fatalf("%s: nodes are not valid JSON: %s", tt.name, err)
+		}
+		if len(nodes) != 2 || nodes[root].Label != root {
+			t.Errorf("%s: nodes are %v", tt.name, nodes)
+		}
+		if !equalStrings(nodes["web/"].Upstream, []string{root}) || !equalStrings(nodes[root].Downstream, []string{"web/"}) {
+			t.Errorf("%s: web/ does not depend on '%s': %v", tt.name, root, nodes)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// OutputImpact lists everything consuming a single output.
+type OutputImpact struct {
+	Output    *Output           `json:"output"`
+	Workspace string            `json:"workspace"`
+	Consumers []*OutputConsumer `json:"consumers"`
+}
+
+// OutputConsumer is an input referring to an output. Consumers that re-export
+// the value of the input in outputs of their own list the impact of those
+// outputs as well.
+type OutputConsumer struct {
+	Input     *Input          `json:"input"`
+	Workspace string          `json:"workspace"`
+	Manual    bool            `json:"manual"`
+	Reexports []*OutputImpact `json:"reexports"`
+}
+
+// GetOutputImpact returns the impact of the outputs of a workspace that match
+// one of the names given.
+func GetOutputImpact(ws *Workspace, names []string) ([]*OutputImpact, error) {
+	impacts := []*OutputImpact{}
+	for _, name := range names {
+		found := false
+		for i, output := range ws.Outputs {
+			if output.Name == name {
+				found = true
+				impacts = append(impacts, getOutputImpact(&ws.Outputs[i], map[*Output]bool{}))
+			}
+		}
+		if !found {
+			return impacts, fmt.Errorf("output '%s' does not exist in workspace '%s'", name, ws.Root)
+		}
+	}
+	return impacts, nil
+}
+
+func getOutputImpact(output *Output, visited map[*Output]bool) *OutputImpact {
+	visited[output] = true
+	impact := &OutputImpact{
+		Output:    output,
+		Workspace: output.BelongsTo.Root,
+		Consumers: []*OutputConsumer{},
+	}
+
+	for _, input := range output.ReferedBy {
+		consumer := &OutputConsumer{
+			Input:     input,
+			Workspace: input.BelongsTo.Root,
+			Manual:    isManualInput(*input),
+			Reexports: []*OutputImpact{},
+		}
+		if !consumer.Manual {
+			for i, o := range input.BelongsTo.Outputs {
+				if visited[&input.BelongsTo.Outputs[i]] {
+					continue
+				}
+				if referencesInput(o.block(), *input) {
+					consumer.Reexports = append(consumer.Reexports, getOutputImpact(&input.BelongsTo.Outputs[i], visited))
+				}
+			}
+		}
+		impact.Consumers = append(impact.Consumers, consumer)
+	}
+
+	sort.Slice(impact.Consumers, func(i, j int) bool { return impact.Consumers[i].Workspace < impact.Consumers[j].Workspace })
+	return impact
+}
+
+// ImpactedWorkspaces returns the roots of all workspaces consuming any of the
+// outputs of the impacts given, sorted.
+func ImpactedWorkspaces(impacts []*OutputImpact) []string {
+	seen := map[string]bool{}
+	var collect func([]*OutputImpact)
+	collect = func(impacts []*OutputImpact) {
+		for _, impact := range impacts {
+			for _, consumer := range impact.Consumers {
+				seen[consumer.Workspace] = true
+				collect(consumer.Reexports)
+			}
+		}
+	}
+	collect(impacts)
+
+	out := []string{}
+	for root := range seen {
+		out = append(out, root)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// PrintOutputImpact writes the impacts given as an indented tree.
+func PrintOutputImpact(w io.Writer, impacts []*OutputImpact) {
+	var print func(impact *OutputImpact, indent string)
+	print = func(impact *OutputImpact, indent string) {
+		for _, consumer := range impact.Consumers {
+			if consumer.Manual {
+				fmt.Fprintf(w, "%s%s (manual reference '%s' in %s)\n", indent, consumer.Workspace, consumer.Input.FullName, strings.Join(consumer.Input.InFile, ", "))
+			} else {
+				fmt.Fprintf(w, "%s%s (input '%s' in %s)\n", indent, consumer.Workspace, consumer.Input.Name, strings.Join(consumer.Input.InFile, ", "))
+			}
+			for _, reexport := range consumer.Reexports {
+				fmt.Fprintf(w, "%s    re-exported as output '%s'\n", indent, reexport.Output.Name)
+				print(reexport, indent+"        ")
+			}
+		}
+	}
+
+	for _, impact := range impacts {
+		fmt.Fprintf(w, "%s %s\n", impact.Workspace, impact.Output.Name)
+		if len(impact.Consumers) == 0 {
+			fmt.Fprintf(w, "    not consumed by any workspace\n")
+		}
+		print(impact, "    ")
+	}
+}
+
+// GetChangedOutputs compares the output blocks of a workspace with the ones
+// found in its terraform files as of the git ref given and returns the names
+// of all outputs that have been added or modified as well as the names of the
+// outputs that have been removed. Files deleted or renamed since ref are
+// considered as well.
+func GetChangedOutputs(ws *Workspace, ref string) ([]string, []string, error) {
+	changed, removed := []string{}, []string{}
+
+	current := map[string]string{}
+	for _, file := range ws.Files {
+		for name, block := range outputBlocks(file.Raw) {
+			current[name] = block
+		}
+	}
+
+	previousFiles, err := gitListDir(ws.Root, ref)
+	if err != nil {
+		return changed, removed, err
+	}
+	previous := map[string]string{}
+	for _, filename := range previousFiles {
+		if filepath.Ext(filename) != tfext {
+			continue
+		}
+		raw, _, err := gitShowFile(filepath.Join(ws.Root, filename), ref)
+		if err != nil {
+			return changed, removed, err
+		}
+		for name, block := range outputBlocks(raw) {
+			previous[name] = block
+		}
+	}
+
+	for name, block := range current {
+		if prev, ok := previous[name]; !ok || prev != block {
+			changed = append(changed, name)
+		}
+	}
+	for name := range previous {
+		if _, ok := current[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+
+	sort.Strings(changed)
+	sort.Strings(removed)
+	return changed, removed, nil
+}
+
+var outputBlockStart = regexp.MustCompile(`output\s*\"(?P<val>[a-zA-Z0-9_-]*)\"\s*\{`)
+
+// outputBlocks returns the source of all output blocks found in raw indexed by
+// the name of the output.
+func outputBlocks(raw []byte) map[string]string {
+	blocks := map[string]string{}
+	for _, loc := range outputBlockStart.FindAllSubmatchIndex(raw, -1) {
+		name := string(raw[loc[2]:loc[3]])
+		end := loc[1] + len(blockBody(raw, loc[1]))
+		if end < len(raw) {
+			// include the closing brace
+			end++
+		}
+		blocks[name] = string(raw[loc[0]:end])
+	}
+	return blocks
+}
+
+// block returns the source of the output block.
+func (o Output) block() string {
+	if o.BelongsTo == nil {
+		return ""
+	}
+	file, ok := o.BelongsTo.Files[o.InFile]
+	if !ok {
+		return ""
+	}
+	return outputBlocks(file.Raw)[o.Name]
+}
+
+// referencesInput returns true if the source given refers to the input. The
+// reference has to end at a word boundary so that an input 'vpc_id' is not
+// found in 'vpc_id_v2'.
+func referencesInput(src string, input Input) bool {
+	re := regexp.MustCompile(`(^|[^a-zA-Z0-9_.-])` + regexp.QuoteMeta(input.FullName) + `($|[^a-zA-Z0-9_-])`)
+	return re.MatchString(src)
+}
+
+func isManualInput(input Input) bool {
+	for _, f := range input.InFile {
+		if f == preFileName || f == postFileName {
+			return true
+		}
+	}
+	return false
+}
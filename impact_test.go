@@ -0,0 +1,174 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetChangedOutputs(t *testing.T) {
+	files := map[string]string{
+		"main.tf":        tfBackend("top") + tfOutput("account", `"123"`),
+		"net/main.tf":    tfBackend("net") + tfOutput("vpc_id", `"vpc"`),
+		"net/subnets.tf": tfOutput("subnet_ids", `["a", "b"]`),
+	}
+	dir, cleanup := gitFixture(t, files)
+	defer cleanup()
+	defer chdir(t, dir)()
+
+	tests := []struct {
+		name      string
+		workspace string
+		write     map[string]string
+		remove    []string
+		changed   []string
+		removed   []string
+	}{
+		{
+			name:      "unchanged",
+			workspace: "net/",
+			changed:   []string{},
+			removed:   []string{},
+		},
+		{
+			name:      "output modified",
+			workspace: "net/",
+			write:     map[string]string{"net/main.tf": tfBackend("net") + tfOutput("vpc_id", `"vpc-2"`)},
+			changed:   []string{"vpc_id"},
+			removed:   []string{},
+		},
+		{
+			name:      "output added",
+			workspace: "net/",
+			write:     map[string]string{"net/zones.tf": tfOutput("zones", `[]`)},
+			changed:   []string{"zones"},
+			removed:   []string{},
+		},
+		{
+			name:      "file deleted",
+			workspace: "net/",
+			remove:    []string{"net/subnets.tf"},
+			changed:   []string{},
+			removed:   []string{"subnet_ids"},
+		},
+		{
+			name:      "file renamed",
+			workspace: "net/",
+			write:     map[string]string{"net/outputs.tf": files["net/subnets.tf"]},
+			remove:    []string{"net/subnets.tf"},
+			changed:   []string{},
+			removed:   []string{},
+		},
+		{
+			name:      "top-level workspace",
+			workspace: "",
+			write:     map[string]string{"main.tf": tfBackend("top")},
+			changed:   []string{},
+			removed:   []string{"account"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeFixture(t, dir, tt.write)
+			for _, f := range tt.remove {
+				if err := os.Remove(filepath.FromSlash(f)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			defer runGit(t, dir, "clean", "-q", "-f", "-d")
+			defer runGit(t, dir, "checkout", "-q", "--", ".")
+
+			workspaces, err := GetWorkspaces(".", []string{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ws, ok := workspaces[tt.workspace]
+			if !ok {
+				t.Fatalf("workspace '%s' not found", tt.workspace)
+			}
+			changed, removed, err := GetChangedOutputs(ws, "HEAD")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equalStrings(changed, tt.changed) {
+				t.Errorf("changed outputs are %q, want %q", changed, tt.changed)
+			}
+			if !equalStrings(removed, tt.removed) {
+				t.Errorf("removed outputs are %q, want %q", removed, tt.removed)
+			}
+		})
+	}
+}
+
+func TestGetOutputImpact(t *testing.T) {
+	files := map[string]string{
+		"net/main.tf": tfBackend("net") + tfOutput("vpc_id", `"vpc"`) + tfOutput("vpc_id_v2", `"vpc-2"`),
+		"api/main.tf": tfBackend("api") + tfRemoteState("net", "net") +
+			tfOutput("url", `"https://${data.terraform_remote_state.net.outputs.vpc_id}"`) +
+			tfOutput("url_v2", "data.terraform_remote_state.net.outputs.vpc_id_v2"),
+		"web/main.tf":      tfBackend("web") + tfRemoteState("api", "api") + tfOutput("host", "data.terraform_remote_state.api.outputs.url"),
+		"web-v2/main.tf":   tfBackend("web-v2") + tfRemoteState("api", "api") + tfOutput("host", "data.terraform_remote_state.api.outputs.url_v2"),
+		"dns/main.tf":      tfBackend("dns"),
+		"dns/PreManual.md": "Point the record to {{net.vpc_id}}.\n",
+	}
+	dir, cleanup := tempFixture(t, files)
+	defer cleanup()
+	defer chdir(t, dir)()
+
+	workspaces, err := GetWorkspaces(".", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		outputs  []string
+		impacted []string
+	}{
+		{[]string{"vpc_id"}, []string{"api/", "dns/", "web/"}},
+		{[]string{"vpc_id_v2"}, []string{"api/", "web-v2/"}},
+		{[]string{"vpc_id", "vpc_id_v2"}, []string{"api/", "dns/", "web-v2/", "web/"}},
+	}
+	for _, tt := range tests {
+		impacts, err := GetOutputImpact(workspaces["net/"], tt.outputs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ImpactedWorkspaces(impacts); !equalStrings(got, tt.impacted) {
+			t.Errorf("outputs %q impact %q, want %q", tt.outputs, got, tt.impacted)
+		}
+	}
+
+	if _, err := GetOutputImpact(workspaces["net/"], []string{"nope"}); err == nil {
+		t.Errorf("expected an error for an output that does not exist")
+	}
+}
+
+func TestOutputBlocks(t *testing.T) {
+	raw := []byte(`output "tags" {
+  value = { env = "prod", team = { name = "net" } }
+}
+
+output "vpc_id" {
+  value     = "vpc"
+  sensitive = true
+}
+
+output "open" {
+  value = "x"
+`)
+	want := map[string]string{
+		"tags":   "output \"tags\" {\n  value = { env = \"prod\", team = { name = \"net\" } }\n}",
+		"vpc_id": "output \"vpc_id\" {\n  value     = \"vpc\"\n  sensitive = true\n}",
+		"open":   "output \"open\" {\n  value = \"x\"\n",
+	}
+	blocks := outputBlocks(raw)
+	if len(blocks) != len(want) {
+		t.Errorf("got %d blocks, want %d", len(blocks), len(want))
+	}
+	for name, block := range want {
+		if blocks[name] != block {
+			t.Errorf("block of output '%s' is %q, want %q", name, blocks[name], block)
+		}
+	}
+}
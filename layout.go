@@ -0,0 +1,153 @@
+package main
+
+import (
+	"sort"
+)
+
+const (
+	layoutMargin    = 20.0
+	layoutGapX      = 80.0
+	layoutGapY      = 24.0
+	layoutNodeH     = 32.0
+	layoutCharWidth = 7.0
+)
+
+// layoutBox is the position and size of a node laid out.
+type layoutBox struct {
+	Layer int     `json:"layer"`
+	Order int     `json:"order"`
+	X     float64 `json:"x"`
+	Y     float64 `json:"y"`
+	W     float64 `json:"w"`
+	H     float64 `json:"h"`
+}
+
+// layeredLayout places the nodes given in layers from left to right so that
+// every edge points from a node to a node in a layer further left, i.e. from
+// consumers to producers. Within a layer nodes are ordered to reduce edge
+// crossings. size returns the width and height of each node. Without nodes
+// the layout has neither width nor height.
+func layeredLayout(nodes []string, edges []*GraphEdge, size func(id string) (float64, float64)) (map[string]*layoutBox, float64, float64) {
+	if len(nodes) == 0 {
+		return map[string]*layoutBox{}, 0, 0
+	}
+
+	out := map[string][]string{}
+	in := map[string][]string{}
+	known := map[string]bool{}
+	for _, n := range nodes {
+		known[n] = true
+	}
+	for _, e := range edges {
+		if known[e.From] && known[e.To] && e.From != e.To {
+			out[e.From] = append(out[e.From], e.To)
+			in[e.To] = append(in[e.To], e.From)
+		}
+	}
+
+	// assign layers by the longest path to a node without outgoing edges,
+	// edges closing a cycle are ignored
+	layer := map[string]int{}
+	visiting := map[string]bool{}
+	var assign func(n string) int
+	assign = func(n string) int {
+		if l, ok := layer[n]; ok {
+			return l
+		}
+		if visiting[n] {
+			return -1
+		}
+		visiting[n] = true
+		l := 0
+		for _, to := range out[n] {
+			if t := assign(to); t >= 0 && t+1 > l {
+				l = t + 1
+			}
+		}
+		visiting[n] = false
+		layer[n] = l
+		return l
+	}
+	layers := [][]string{}
+	for _, n := range nodes {
+		l := assign(n)
+		for len(layers) <= l {
+			layers = append(layers, []string{})
+		}
+		layers[l] = append(layers[l], n)
+	}
+	for _, l := range layers {
+		sort.Strings(l)
+	}
+
+	// reduce crossings by sorting layers by the barycenter of their neighbours
+	position := map[string]int{}
+	updatePositions := func() {
+		for _, l := range layers {
+			for i, n := range l {
+				position[n] = i
+			}
+		}
+	}
+	updatePositions()
+	barycenter := func(n string, neighbours []string, fallback int) float64 {
+		if len(neighbours) == 0 {
+			return float64(fallback)
+		}
+		sum := 0.0
+		for _, m := range neighbours {
+			sum += float64(position[m])
+		}
+		return sum / float64(len(neighbours))
+	}
+	for sweep := 0; sweep < 8; sweep++ {
+		for i := range layers {
+			l := layers[i]
+			if sweep%2 == 1 {
+				l = layers[len(layers)-1-i]
+			}
+			bc := map[string]float64{}
+			for _, n := range l {
+				if sweep%2 == 0 {
+					bc[n] = barycenter(n, out[n], position[n])
+				} else {
+					bc[n] = barycenter(n, in[n], position[n])
+				}
+			}
+			sort.SliceStable(l, func(a, b int) bool { return bc[l[a]] < bc[l[b]] })
+			updatePositions()
+		}
+	}
+
+	// compute coordinates
+	boxes := map[string]*layoutBox{}
+	x, height := layoutMargin, 0.0
+	for li, l := range layers {
+		width, y := 0.0, layoutMargin
+		for i, n := range l {
+			w, h := size(n)
+			boxes[n] = &layoutBox{Layer: li, Order: i, X: x, Y: y, W: w, H: h}
+			y += h + layoutGapY
+			if w > width {
+				width = w
+			}
+		}
+		for _, n := range l {
+			boxes[n].W = width
+		}
+		if y > height {
+			height = y
+		}
+		x += width + layoutGapX
+	}
+	return boxes, x - layoutGapX + layoutMargin, height - layoutGapY + layoutMargin
+}
+
+// labelSize returns the size of a box fitting the label given.
+func labelSize(label string) (float64, float64) {
+	w := float64(len(label))*layoutCharWidth + 24
+	if w < 80 {
+		w = 80
+	}
+	return w, layoutNodeH
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestLayeredLayout(t *testing.T) {
+	size := func(id string) (float64, float64) { return float64(len(id)) * 10, layoutNodeH }
+	edges := []*GraphEdge{
+		{From: "api", To: "net"},
+		{From: "web", To: "api"},
+		{From: "dns", To: "net"},
+		{From: "a", To: "b"},
+		{From: "b", To: "a"},
+	}
+
+	tests := []struct {
+		nodes  []string
+		layers map[string]int
+		width  float64
+		height float64
+	}{
+		{[]string{}, map[string]int{}, 0, 0},
+		{[]string{"net"}, map[string]int{"net": 0}, 2*layoutMargin + 30, 2*layoutMargin + layoutNodeH},
+		{[]string{"net", "api", "web", "dns"}, map[string]int{"net": 0, "api": 1, "dns": 1, "web": 2}, 2*layoutMargin + 30 + 30 + 30 + 2*layoutGapX, 2*layoutMargin + 2*layoutNodeH + layoutGapY},
+		{[]string{"a", "b"}, map[string]int{"a": 1, "b": 0}, 2*layoutMargin + 10 + 10 + layoutGapX, 2*layoutMargin + layoutNodeH},
+	}
+	for _, tt := range tests {
+		boxes, width, height := layeredLayout(tt.nodes, edges, size)
+		if width != tt.width || height != tt.height {
+			t.Errorf("nodes %q are laid out in %.0fx%.0f, want %.0fx%.0f", tt.nodes, width, height, tt.width, tt.height)
+		}
+		for n, l := range tt.layers {
+			if boxes[n] == nil || boxes[n].Layer != l {
+				t.Errorf("node '%s' of %q is not in layer %d", n, tt.nodes, l)
+			}
+		}
+	}
+}
+
+func TestSVGEmptyGraph(t *testing.T) {
+	var out bytes.Buffer
+	if err := graphExporters["svg"].Export(&out, &Graph{}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `width="0" height="0" viewBox="0 0 0 0"`) {
+		t.Errorf("empty graph has an unexpected size:\n%s", out.String())
+	}
+}
+
+func TestSVGDetailedClusters(t *testing.T) {
+	dir, cleanup := tempFixture(t, map[string]string{
+		"net/main.tf": tfBackend("net") + tfOutput("vpc_id", `"vpc"`) + tfOutput("zone", `"zone"`),
+		"api/main.tf": tfBackend("api") + tfRemoteState("net", "net") + tfOutput("url", "data.terraform_remote_state.net.outputs.vpc_id"),
+	})
+	defer cleanup()
+	defer chdir(t, dir)()
+
+	workspaces, err := GetWorkspaces(".", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := graphExporters["svg"].Export(&out, BuildGraph(workspaces, true)); err != nil {
+		t.Fatal(err)
+	}
+	svg := out.String()
+	counts := map[string]int{`class="node"`: 2, `data-kind="outputs"`: 2, `data-kind="inputs"`: 1, `class="port"`: 4, `class="edge"`: 1}
+	for s, want := range counts {
+		if got := strings.Count(svg, s); got != want {
+			t.Errorf("svg contains %d times '%s', want %d", got, s, want)
+		}
+	}
+}
+
+func TestGraphExporterPNG(t *testing.T) {
+	_, err := GetGraphExporter("png")
+	if err == nil || !strings.Contains(err.Error(), "'svg'") {
+		t.Errorf("expected an error suggesting svg, got %v", err)
+	}
+}
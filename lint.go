@@ -2,61 +2,206 @@ package main
 
 import (
 	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 )
 
-func Lint(workspaces map[string]*Workspace) map[string][]string {
-	out := map[string][]string{}
+const (
+	lintSeverityOff     = "off"
+	lintSeverityWarning = "warning"
+	lintSeverityError   = "error"
+)
 
-	outputErrors := lintUnusedOuputs(workspaces)
-	if len(outputErrors) > 0 {
-		out["Usused Outputs"] = outputErrors
-	}
+// lintIgnoreComment suppresses findings of the rules listed, or of all rules
+// if none are listed, on the same or the following line.
+var lintIgnoreComment = regexp.MustCompile(`(?:#|//|<!--)\s*solaris:ignore\b(.*)`)
+
+const (
+	lintRuleUnusedOutput       = "unused-output"
+	lintRuleInexistentInput    = "inexistent-input"
+	lintRuleUnusedDataSource   = "unused-data-source"
+	lintRuleCircularDependency = "circular-dependency"
+	lintRuleDuplicateBackend   = "duplicate-backend"
+	lintRuleMissingBackend     = "missing-backend"
+	lintRuleUnknownRemoteState = "unknown-remote-state"
+	lintRuleBackendDrift       = "backend-drift"
+	lintRuleManualUnknown      = "manual-unknown-output"
+	lintRuleManualAmbiguous    = "manual-ambiguous-path"
+	lintRuleManualMalformed    = "manual-malformed"
+	lintRuleManualSensitive    = "manual-sensitive"
+	lintRuleManualForwardRef   = "manual-forward-ref"
+	lintRuleManualUnplanned    = "manual-unplanned-ref"
+)
+
+// LintRule describes a check performed by the linter.
+type LintRule struct {
+	ID          string `json:"id"`
+	Severity    string `json:"severity"`
+	Category    string `json:"category"`
+	Description string `json:"description"`
+}
+
+var lintRules = []LintRule{
+	{lintRuleUnusedOutput, lintSeverityWarning, "Usused Outputs", "output is not referenced by any other workspace or manual"},
+	{lintRuleInexistentInput, lintSeverityError, "Inexistent Inputs", "input refers to an output that does not exist"},
+	{lintRuleUnusedDataSource, lintSeverityWarning, "Unused terraform_remote_state data sources", "terraform_remote_state data source is never read"},
+	{lintRuleCircularDependency, lintSeverityError, "Circular Dependencies", "workspace depends on itself through other workspaces"},
+	{lintRuleDuplicateBackend, lintSeverityError, "Duplicate Backends", "workspace shares its remote state with another workspace"},
+	{lintRuleMissingBackend, lintSeverityWarning, "Missing Backends", "workspace has no complete s3 backend configuration"},
+	{lintRuleUnknownRemoteState, lintSeverityWarning, "Unknown Remote States", "terraform_remote_state data source matches no workspace"},
+	{lintRuleBackendDrift, lintSeverityError, "Backend Drift", "terraform_remote_state data source differs from a workspace backend only in profile or region"},
+	{lintRuleManualUnknown, lintSeverityError, "Unknown Manual References", "manual refers to a workspace or output that does not exist"},
+	{lintRuleManualAmbiguous, lintSeverityError, "Ambiguous Manual References", "manual refers to a workspace path matching several workspaces"},
+	{lintRuleManualMalformed, lintSeverityError, "Malformed Manual References", "manual contains a reference not of the form '{{workspace.output}}'"},
+	{lintRuleManualSensitive, lintSeverityWarning, "Sensitive Manual References", "manual refers to a sensitive output, rendering it discloses its value"},
+	{lintRuleManualForwardRef, lintSeverityError, "Forward Manual References", "manual refers to a workspace not applied before the workspace of the manual"},
+	{lintRuleManualUnplanned, lintSeverityError, "Unplanned Manual References", "manual refers to a workspace missing from the execution plan"},
+}
 
-	inputErrors := lintInexistentInputs(workspaces)
-	if len(inputErrors) > 0 {
-		out["Inexistent Inputs"] = inputErrors
+// GetLintRule returns the rule with the id given.
+func GetLintRule(id string) (LintRule, bool) {
+	for _, r := range lintRules {
+		if r.ID == id {
+			return r, true
+		}
 	}
+	return LintRule{}, false
+}
 
-	dataSourceErrors := lintUnusedRemoteStateDataSources(workspaces)
-	if len(dataSourceErrors) > 0 {
-		out["Unused terraform_remote_state data sources"] = dataSourceErrors
+// LintFinding is a single problem found while linting workspaces. File is
+// relative to the base directory, Line is 0 if unknown.
+type LintFinding struct {
+	Rule      string `json:"rule"`
+	Severity  string `json:"severity"`
+	Workspace string `json:"workspace"`
+	File      string `json:"file,omitempty"`
+	Line      int    `json:"line,omitempty"`
+	Message   string `json:"message"`
+	// Subject identifies the element the finding is about within its
+	// workspace independently of its location, e.g. the name of an output.
+	Subject string `json:"subject"`
+}
+
+func newLintFinding(rule string, ws *Workspace, subject, file string, line int, message string) LintFinding {
+	r, _ := GetLintRule(rule)
+	f := LintFinding{
+		Rule:      rule,
+		Severity:  r.Severity,
+		Workspace: ws.Root,
+		Line:      line,
+		Message:   message,
+		Subject:   subject,
+	}
+	if file != "" {
+		f.File = filepath.ToSlash(filepath.Join(ws.Root, file))
 	}
+	return f
+}
 
-	circularErrors := lintCirularDependencies(workspaces)
-	if len(circularErrors) > 0 {
-		out["Circular Dependencies"] = circularErrors
+// Lint returns the messages of all problems found grouped by category.
+func Lint(workspaces map[string]*Workspace) map[string][]string {
+	out := map[string][]string{}
+	for _, f := range LintFindings(workspaces) {
+		r, _ := GetLintRule(f.Rule)
+		out[r.Category] = append(out[r.Category], f.Message)
 	}
+	return out
+}
+
+// LintFindings returns all problems found in the workspaces given, sorted by
+// location.
+func LintFindings(workspaces map[string]*Workspace) []LintFinding {
+	findings := []LintFinding{}
+	findings = append(findings, lintUnusedOuputs(workspaces)...)
+	findings = append(findings, lintInexistentInputs(workspaces)...)
+	findings = append(findings, lintUnusedRemoteStateDataSources(workspaces)...)
+	findings = append(findings, lintCirularDependencies(workspaces)...)
+	findings = append(findings, lintBackends(workspaces)...)
+	findings = append(findings, lintUnknownRemoteStates(workspaces)...)
+	findings = append(findings, lintBackendDrift(workspaces)...)
+	findings = append(findings, lintManualReferences(workspaces)...)
+	sortLintFindings(findings)
+	return findings
+}
 
+// LintWorkspaces returns the findings of all rules enabled by the config
+// given with their configured severity. Findings suppressed by comments,
+// unknown remote states configured as external and inputs read from them are
+// omitted.
+func LintWorkspaces(workspaces map[string]*Workspace, cfg LintConfig) []LintFinding {
+	out := []LintFinding{}
+	for _, f := range LintFindings(workspaces) {
+		r, _ := GetLintRule(f.Rule)
+		f.Severity = cfg.severity(r, f.Workspace)
+		if f.Severity == lintSeverityOff {
+			continue
+		}
+		ws, ok := workspaces[f.Workspace]
+		if ok && ws.suppresses(f) {
+			continue
+		}
+		if ok && f.Rule == lintRuleUnknownRemoteState && cfg.isExternal(ws.dependency(f.Subject)) {
+			continue
+		}
+		if ok && f.Rule == lintRuleInexistentInput && ws.readsExternal(f.Subject, cfg) {
+			continue
+		}
+		out = append(out, f)
+	}
 	return out
 }
 
-func lintUnusedOuputs(workspaces map[string]*Workspace) []string {
-	errs := []string{}
+func sortLintFindings(findings []LintFinding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Workspace != b.Workspace {
+			return a.Workspace < b.Workspace
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		if a.Rule != b.Rule {
+			return a.Rule < b.Rule
+		}
+		return a.Message < b.Message
+	})
+}
+
+func lintUnusedOuputs(workspaces map[string]*Workspace) []LintFinding {
+	errs := []LintFinding{}
 	for name, workspace := range workspaces {
 		for _, output := range workspace.Outputs {
 			if len(output.ReferedBy) == 0 {
-				errs = append(errs, fmt.Sprintf("output '%s' of workspace '%s' (in file '%s') seems to be unused", output.Name, name, output.InFile))
+				line := workspace.lineOf(output.InFile, regexp.MustCompile(`output\s*"`+regexp.QuoteMeta(output.Name)+`"`))
+				errs = append(errs, newLintFinding(lintRuleUnusedOutput, workspace, output.Name, output.InFile, line,
+					fmt.Sprintf("output '%s' of workspace '%s' (in file '%s') seems to be unused", output.Name, name, output.InFile)))
 			}
 		}
 	}
 	return errs
 }
 
-func lintInexistentInputs(workspaces map[string]*Workspace) []string {
-	errs := []string{}
+func lintInexistentInputs(workspaces map[string]*Workspace) []LintFinding {
+	errs := []LintFinding{}
 	for name, workspace := range workspaces {
 		for _, input := range workspace.Inputs {
 			if input.ReferesTo == nil {
-				errs = append(errs, fmt.Sprintf("input '%s' of workspace '%s' (in file '%s') seems refer to an inexistent output", input.FullName, name, input.InFile))
+				file, line := workspace.inputLocation(input)
+				errs = append(errs, newLintFinding(lintRuleInexistentInput, workspace, input.FullName, file, line,
+					fmt.Sprintf("input '%s' of workspace '%s' (in file '%s') seems refer to an inexistent output", input.FullName, name, input.InFile)))
 			}
 		}
 	}
 	return errs
 }
 
-func lintUnusedRemoteStateDataSources(workspaces map[string]*Workspace) []string {
-	errs := []string{}
+func lintUnusedRemoteStateDataSources(workspaces map[string]*Workspace) []LintFinding {
+	errs := []LintFinding{}
 	for name, workspace := range workspaces {
 		for _, dep := range workspace.Dependencies {
 			depUsed := false
@@ -66,43 +211,378 @@ func lintUnusedRemoteStateDataSources(workspaces map[string]*Workspace) []string
 				}
 			}
 			if !depUsed {
-				errs = append(errs, fmt.Sprintf("terraform_remote_state data source '%s' in workspace '%s' (in file '%s') seems to be unused", dep.Name, name, dep.InFile))
+				line := workspace.lineOf(dep.InFile, regexp.MustCompile(`data\s*"terraform_remote_state"\s*"`+regexp.QuoteMeta(dep.Name)+`"`))
+				errs = append(errs, newLintFinding(lintRuleUnusedDataSource, workspace, dep.Name, dep.InFile, line,
+					fmt.Sprintf("terraform_remote_state data source '%s' in workspace '%s' (in file '%s') seems to be unused", dep.Name, name, dep.InFile)))
 			}
 		}
 	}
 	return errs
 }
 
-func lintCirularDependencies(workspaces map[string]*Workspace) []string {
-	var checkCircular func(ws *Workspace, wsname string, dejavu []string) error
-	checkCircular = func(ws *Workspace, wsname string, dejavu []string) error {
+func lintCirularDependencies(workspaces map[string]*Workspace) []LintFinding {
+	var checkCircular func(ws *Workspace, wsname string, dejavu []string) []string
+	checkCircular = func(ws *Workspace, wsname string, dejavu []string) []string {
 		for _, v := range dejavu {
 			if wsname == v {
-				return fmt.Errorf("%s -> %s", strings.Join(dejavu, " -> "), wsname)
+				return append(dejavu, wsname)
 			}
 		}
 
 		dejavu = append(dejavu, wsname)
 
 		for _, input := range ws.Inputs {
-			if input.ReferesTo == nil {
+			// manuals may refer to outputs of their own workspace
+			if input.ReferesTo == nil || (input.ReferesTo.BelongsTo == ws && isManualInput(input)) {
 				continue
 			}
 
-			err := checkCircular(input.ReferesTo.BelongsTo, input.ReferesTo.BelongsTo.Root, dejavu)
-			if err != nil {
-				return err
+			cycle := checkCircular(input.ReferesTo.BelongsTo, input.ReferesTo.BelongsTo.Root, dejavu)
+			if cycle != nil {
+				return cycle
 			}
 		}
 		return nil
 	}
-	errs := []string{}
+	errs := []LintFinding{}
+	for name, workspace := range workspaces {
+		cycle := checkCircular(workspace, name, []string{})
+
+		if cycle != nil {
+			// point to the input leading into the cycle
+			file, line := "", 0
+			for _, input := range workspace.Inputs {
+				if input.ReferesTo != nil && input.ReferesTo.BelongsTo.Root == cycle[1] {
+					file, line = workspace.inputLocation(input)
+					break
+				}
+			}
+			errs = append(errs, newLintFinding(lintRuleCircularDependency, workspace, cycleSubject(cycle), file, line,
+				fmt.Sprintf("circular dependency in workspace '%s': '%s'", name, strings.Join(cycle, " -> "))))
+		}
+	}
+	return errs
+}
+
+// lintBackends reports workspaces without a resolvable backend and those
+// sharing their backend with other workspaces, which would corrupt each others
+// state.
+func lintBackends(workspaces map[string]*Workspace) []LintFinding {
+	errs := []LintFinding{}
+	for name, workspace := range workspaces {
+		rs := workspace.RemoteState
+		if rs.isEmpty() {
+			errs = append(errs, newLintFinding(lintRuleMissingBackend, workspace, "", "", 0,
+				fmt.Sprintf("workspace '%s' has no s3 backend with bucket, key, profile and region, it can not be referenced by other workspaces", name)))
+			continue
+		}
+
+		others := []string{}
+		for otherName, other := range workspaces {
+			if otherName != name && other.RemoteState.equals(rs) {
+				others = append(others, otherName)
+			}
+		}
+		if len(others) == 0 {
+			continue
+		}
+		sort.Strings(others)
+		line := workspace.lineOf(rs.InFile, regexp.MustCompile(`terraform\s*\{`))
+		errs = append(errs, newLintFinding(lintRuleDuplicateBackend, workspace, rs.Bucket+"/"+rs.Key, rs.InFile, line,
+			fmt.Sprintf("backend of workspace '%s' (bucket '%s', key '%s', profile '%s', region '%s') is also used by '%s'", name, rs.Bucket, rs.Key, rs.Profile, rs.Region, strings.Join(others, "', '"))))
+	}
+	return errs
+}
+
+// lintUnknownRemoteStates reports terraform_remote_state data sources whose
+// backend matches no workspace. Data sources matching the bucket and key of a
+// workspace are not reported even if profile or region differ.
+func lintUnknownRemoteStates(workspaces map[string]*Workspace) []LintFinding {
+	errs := []LintFinding{}
+	for name, workspace := range workspaces {
+		for _, dep := range workspace.Dependencies {
+			if workspace.Files[dep.InFile] == nil {
+				continue
+			}
+
+			known := false
+			for _, other := range workspaces {
+				rs := other.RemoteState
+				if rs.Bucket == dep.Bucket && rs.Key == dep.Key {
+					known = true
+					break
+				}
+			}
+			if known {
+				continue
+			}
+
+			msg := fmt.Sprintf("terraform_remote_state data source '%s' in workspace '%s' (in file '%s') refers to bucket '%s' and key '%s' which belong to no workspace", dep.Name, name, dep.InFile, dep.Bucket, dep.Key)
+			if suggestion := similarWorkspace(workspaces, dep); suggestion != nil {
+				msg += fmt.Sprintf(", did you mean bucket '%s' and key '%s' of workspace '%s'?", suggestion.RemoteState.Bucket, suggestion.RemoteState.Key, suggestion.Root)
+			}
+			line := workspace.lineOf(dep.InFile, regexp.MustCompile(`data\s*"terraform_remote_state"\s*"`+regexp.QuoteMeta(dep.Name)+`"`))
+			errs = append(errs, newLintFinding(lintRuleUnknownRemoteState, workspace, dep.Name, dep.InFile, line, msg))
+		}
+	}
+	return errs
+}
+
+// lintBackendDrift reports terraform_remote_state data sources reading the
+// bucket and key of a workspace with a different profile or region. These do
+// not create a dependency on the workspace.
+func lintBackendDrift(workspaces map[string]*Workspace) []LintFinding {
+	errs := []LintFinding{}
+	for name, workspace := range workspaces {
+		for _, dep := range workspace.Dependencies {
+			if workspace.Files[dep.InFile] == nil {
+				continue
+			}
+
+			drifted := []*Workspace{}
+			matched := false
+			for _, root := range sortedWorkspaceNames(workspaces) {
+				rs := workspaces[root].RemoteState
+				if rs.equals(dep) {
+					matched = true
+					break
+				}
+				if rs.Bucket == dep.Bucket && rs.Key == dep.Key {
+					drifted = append(drifted, workspaces[root])
+				}
+			}
+			if matched || len(drifted) == 0 {
+				continue
+			}
+
+			line := workspace.lineOf(dep.InFile, regexp.MustCompile(`data\s*"terraform_remote_state"\s*"`+regexp.QuoteMeta(dep.Name)+`"`))
+			for _, producer := range drifted {
+				rs := producer.RemoteState
+				diffs := []string{}
+				if rs.Profile != dep.Profile {
+					diffs = append(diffs, fmt.Sprintf("profile '%s' instead of '%s'", dep.Profile, rs.Profile))
+				}
+				if rs.Region != dep.Region {
+					diffs = append(diffs, fmt.Sprintf("region '%s' instead of '%s'", dep.Region, rs.Region))
+				}
+				errs = append(errs, newLintFinding(lintRuleBackendDrift, workspace, dep.Name, dep.InFile, line,
+					fmt.Sprintf("terraform_remote_state data source '%s' in workspace '%s' (in file '%s') reads the state of workspace '%s' with %s, no dependency on '%s' was created", dep.Name, name, dep.InFile, producer.Root, strings.Join(diffs, " and "), producer.Root)))
+			}
+		}
+	}
+	return errs
+}
+
+// lintManualReferences reports references in manuals that are malformed, can
+// not be resolved, disclose sensitive outputs or refer to workspaces applied
+// later than the workspace of the manual or not planned at all.
+func lintManualReferences(workspaces map[string]*Workspace) []LintFinding {
+	errs := []LintFinding{}
+	tiers := workspaceTiers(workspaces)
 	for name, workspace := range workspaces {
-		err := checkCircular(workspace, name, []string{})
+		for _, ref := range workspace.manualReferences() {
+			finding := func(rule, msg string) {
+				errs = append(errs, newLintFinding(rule, workspace, ref.Match, ref.InFile, ref.Line, msg))
+			}
+			if ref.Malformed {
+				finding(lintRuleManualMalformed,
+					fmt.Sprintf("reference '%s' in manual '%s' of workspace '%s' is malformed, use '{{path/to/workspace.output}}'", ref.Match, ref.InFile, name))
+				continue
+			}
 
-		if err != nil {
-			errs = append(errs, fmt.Sprintf("circular dependency in workspace '%s': '%s'", name, err))
+			target, output, candidates := ref.resolve(workspaces)
+			switch {
+			case len(candidates) > 1:
+				finding(lintRuleManualAmbiguous,
+					fmt.Sprintf("reference '%s' in manual '%s' of workspace '%s' is ambiguous, '%s' matches '%s'", ref.Match, ref.InFile, name, ref.Workspace, strings.Join(candidates, "', '")))
+				continue
+			case target == nil:
+				finding(lintRuleManualUnknown,
+					fmt.Sprintf("reference '%s' in manual '%s' of workspace '%s' refers to workspace '%s' which does not exist", ref.Match, ref.InFile, name, ref.Workspace))
+				continue
+			case output == nil:
+				finding(lintRuleManualUnknown,
+					fmt.Sprintf("reference '%s' in manual '%s' of workspace '%s' refers to output '%s' which does not exist in workspace '%s'", ref.Match, ref.InFile, name, ref.Output, target.Root))
+				continue
+			}
+
+			if output.Sensitive {
+				finding(lintRuleManualSensitive,
+					fmt.Sprintf("reference '%s' in manual '%s' of workspace '%s' refers to sensitive output '%s' of workspace '%s'", ref.Match, ref.InFile, name, output.Name, target.Root))
+			}
+
+			// workspaces are missing from the plan if they are part of a
+			// cycle or share their remote state with another workspace
+			tier, planned := tiers[workspace]
+			targetTier, targetPlanned := tiers[target]
+			switch {
+			case target != workspace && !targetPlanned:
+				finding(lintRuleManualUnplanned,
+					fmt.Sprintf("reference '%s' in manual '%s' of workspace '%s' refers to workspace '%s' which is not in the execution plan", ref.Match, ref.InFile, name, target.Root))
+			case target == workspace && ref.InFile == preFileName,
+				target != workspace && planned && targetTier >= tier:
+				finding(lintRuleManualForwardRef,
+					fmt.Sprintf("reference '%s' in manual '%s' of workspace '%s' refers to workspace '%s' which is not applied before it", ref.Match, ref.InFile, name, target.Root))
+			}
 		}
 	}
 	return errs
 }
+
+// similarWorkspace returns the workspace whose bucket and key are closest to
+// the ones of the remote state given or nil if none is similar enough to be a
+// likely typo.
+func similarWorkspace(workspaces map[string]*Workspace, rs RemoteState) *Workspace {
+	target := rs.Bucket + "/" + rs.Key
+	var best *Workspace
+	bestDistance := len(target)/3 + 1
+	for _, root := range sortedWorkspaceNames(workspaces) {
+		other := workspaces[root].RemoteState
+		if other.isEmpty() {
+			continue
+		}
+		d := levenshtein(target, other.Bucket+"/"+other.Key)
+		if d < bestDistance {
+			best, bestDistance = workspaces[root], d
+		}
+	}
+	return best
+}
+
+// levenshtein returns the number of single character edits needed to turn a
+// into b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// dependency returns the terraform_remote_state data source with the name
+// given.
+func (ws *Workspace) dependency(name string) RemoteState {
+	for _, dep := range ws.Dependencies {
+		if dep.Name == name && ws.Files[dep.InFile] != nil {
+			return dep
+		}
+	}
+	return RemoteState{}
+}
+
+// readsExternal returns true if the input with the full name given is read
+// from a remote state configured as external.
+func (ws *Workspace) readsExternal(fullName string, cfg LintConfig) bool {
+	for _, input := range ws.Inputs {
+		if input.FullName == fullName && input.Dependency != nil && cfg.isExternal(*input.Dependency) {
+			return true
+		}
+	}
+	return false
+}
+
+// cycleSubject returns the workspaces forming the cycle at the end of the path
+// given, sorted to not depend on the workspace the cycle was entered from.
+func cycleSubject(path []string) string {
+	last := path[len(path)-1]
+	members := []string{}
+	for i, root := range path[:len(path)-1] {
+		if root == last {
+			members = append(members, path[i:len(path)-1]...)
+			break
+		}
+	}
+	sort.Strings(members)
+	return strings.Join(members, ", ")
+}
+
+// inputLocation returns the first file and line an input is referenced in.
+func (ws *Workspace) inputLocation(input Input) (string, int) {
+	if len(input.InFile) == 0 {
+		return "", 0
+	}
+	files := append([]string{}, input.InFile...)
+	sort.Strings(files)
+	return files[0], ws.lineOf(files[0], regexp.MustCompile(regexp.QuoteMeta(input.FullName)))
+}
+
+// suppresses returns true if the finding given is suppressed by an ignore
+// comment on its line or the line before.
+func (ws *Workspace) suppresses(f LintFinding) bool {
+	if f.File == "" || f.Line == 0 {
+		return false
+	}
+	filename, err := filepath.Rel(ws.Root, filepath.FromSlash(f.File))
+	if err != nil {
+		return false
+	}
+	lines := strings.Split(string(ws.content(filename)), "\n")
+	for _, i := range []int{f.Line - 1, f.Line - 2} {
+		if i < 0 || i >= len(lines) {
+			continue
+		}
+		match := lintIgnoreComment.FindStringSubmatch(lines[i])
+		if match == nil {
+			continue
+		}
+		rules := strings.FieldsFunc(strings.TrimSuffix(strings.TrimSpace(match[1]), "-->"), func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		if len(rules) == 0 {
+			return true
+		}
+		for _, r := range rules {
+			if r == f.Rule {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// content returns the content of a terraform file or manual of the workspace.
+func (ws *Workspace) content(filename string) []byte {
+	switch {
+	case ws.Files[filename] != nil:
+		return ws.Files[filename].Raw
+	case filename == preFileName:
+		return []byte(ws.PreManual)
+	case filename == postFileName:
+		return []byte(ws.PostManual)
+	}
+	return nil
+}
+
+// lineOf returns the line of the first match of re in the file of the
+// workspace given or 0 if there is none.
+func (ws *Workspace) lineOf(filename string, re *regexp.Regexp) int {
+	raw := ws.content(filename)
+	loc := re.FindIndex(raw)
+	if loc == nil {
+		return 0
+	}
+	return strings.Count(string(raw[:loc[0]]), "\n") + 1
+}
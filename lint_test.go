@@ -0,0 +1,479 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// findingKeys returns the rule and workspace of each finding, the order of
+// the findings given is kept.
+func findingKeys(findings []LintFinding) []string {
+	out := []string{}
+	for _, f := range findings {
+		out = append(out, fmt.Sprintf("%s %s", f.Rule, f.Workspace))
+	}
+	return out
+}
+
+// lintCase is a fixture and the findings of a single rule expected, given as
+// 'workspace file:line subject'.
+type lintCase struct {
+	name  string
+	files map[string]string
+	want  []string
+}
+
+// runLintCases lints the fixture of each case and compares the findings of
+// the rule given.
+func runLintCases(t *testing.T, rule string, cases []lintCase) {
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, cleanup := tempFixture(t, tc.files)
+			defer cleanup()
+			defer chdir(t, dir)()
+
+			workspaces, err := GetWorkspaces(".", []string{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, f := range LintFindings(workspaces) {
+				if f.Rule == rule {
+					got = append(got, fmt.Sprintf("%s %s:%d %s", f.Workspace, f.File, f.Line, f.Subject))
+				}
+			}
+			if !equalStrings(got, tc.want) {
+				t.Errorf("%s findings are %q, want %q", rule, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestManualSelfReference(t *testing.T) {
+	files := map[string]string{
+		"net/main.tf":       tfBackend("net") + tfOutput("vpc_id", `"vpc"`),
+		"net/PostManual.md": "Verify {{net.vpc_id}} is reachable.\n",
+		"api/main.tf":       tfBackend("api") + tfRemoteState("net", "net") + tfOutput("url", "data.terraform_remote_state.net.outputs.vpc_id"),
+	}
+	dir, cleanup := tempFixture(t, files)
+	defer cleanup()
+	defer chdir(t, dir)()
+
+	workspaces, err := GetWorkspaces(".", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	net := workspaces["net/"]
+
+	if len(net.Inputs) != 1 || net.Inputs[0].ReferesTo != &net.Outputs[0] {
+		t.Errorf("manual reference does not refer to the output of its own workspace")
+	}
+	if len(net.Dependencies) != 0 {
+		t.Errorf("manual reference to its own workspace is a dependency: %v", net.Dependencies)
+	}
+	if got := findingKeys(LintFindings(workspaces)); !equalStrings(got, []string{"unused-output api/"}) {
+		t.Errorf("expected only the unused output of 'api/', got %q", got)
+	}
+	for _, roots := range [][]string{{}, {"net/"}} {
+		plan, err := BuildExecutionPlan(workspaceSlice(workspaces), roots, func(string) {})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := planRoots(plan); !equalStrings(got, []string{"net/", "api/"}) {
+			t.Errorf("roots %q are planned as %q", roots, got)
+		}
+	}
+}
+
+func TestLintUnusedOutput(t *testing.T) {
+	runLintCases(t, lintRuleUnusedOutput, []lintCase{
+		{
+			name: "consumed by terraform",
+			files: map[string]string{
+				"net/main.tf": tfBackend("net") + tfOutput("vpc_id", `"vpc"`),
+				"api/main.tf": tfBackend("api") + tfRemoteState("net", "net") + "\nlocals { vpc = data.terraform_remote_state.net.outputs.vpc_id }\n",
+			},
+			want: []string{},
+		},
+		{
+			name: "consumed by manual",
+			files: map[string]string{
+				"net/main.tf":      tfBackend("net") + tfOutput("vpc_id", `"vpc"`),
+				"api/main.tf":      tfBackend("api"),
+				"api/PreManual.md": "Peer {{net.vpc_id}}.\n",
+			},
+			want: []string{},
+		},
+		{
+			name: "not consumed",
+			files: map[string]string{
+				"net/main.tf": tfBackend("net") + tfOutput("vpc_id", `"vpc"`) + tfOutput("spare", `"x"`),
+				"api/main.tf": tfBackend("api") + tfRemoteState("net", "net") + "\nlocals { vpc = data.terraform_remote_state.net.outputs.vpc_id }\n",
+			},
+			want: []string{"net/ net/main.tf:14 spare"},
+		},
+	})
+}
+
+func TestLintInexistentInput(t *testing.T) {
+	runLintCases(t, lintRuleInexistentInput, []lintCase{
+		{
+			name: "output exists",
+			files: map[string]string{
+				"net/main.tf": tfBackend("net") + tfOutput("vpc_id", `"vpc"`),
+				"api/main.tf": tfBackend("api") + tfRemoteState("net", "net") + tfOutput("vpc", "data.terraform_remote_state.net.outputs.vpc_id"),
+			},
+			want: []string{},
+		},
+		{
+			name: "output does not exist",
+			files: map[string]string{
+				"net/main.tf": tfBackend("net") + tfOutput("vpc_id", `"vpc"`),
+				"api/main.tf": tfBackend("api") + tfRemoteState("net", "net") + tfOutput("vpc", "data.terraform_remote_state.net.outputs.vpc"),
+			},
+			want: []string{"api/ api/main.tf:21 data.terraform_remote_state.net.outputs.vpc"},
+		},
+	})
+}
+
+func TestLintUnusedDataSource(t *testing.T) {
+	runLintCases(t, lintRuleUnusedDataSource, []lintCase{
+		{
+			name: "read",
+			files: map[string]string{
+				"net/main.tf": tfBackend("net") + tfOutput("vpc_id", `"vpc"`),
+				"api/main.tf": tfBackend("api") + tfRemoteState("net", "net") + tfOutput("vpc", "data.terraform_remote_state.net.outputs.vpc_id"),
+			},
+			want: []string{},
+		},
+		{
+			name: "never read",
+			files: map[string]string{
+				"net/main.tf": tfBackend("net") + tfOutput("vpc_id", `"vpc"`),
+				"api/main.tf": tfBackend("api") + tfRemoteState("net", "net"),
+			},
+			want: []string{"api/ api/main.tf:10 net"},
+		},
+	})
+}
+
+func TestLintCircularDependency(t *testing.T) {
+	runLintCases(t, lintRuleCircularDependency, []lintCase{
+		{
+			name: "chain",
+			files: map[string]string{
+				"a/main.tf": tfBackend("a") + tfOutput("x", `"x"`),
+				"b/main.tf": tfBackend("b") + tfRemoteState("a", "a") + tfOutput("y", "data.terraform_remote_state.a.outputs.x"),
+			},
+			want: []string{},
+		},
+		{
+			name: "cycle",
+			files: map[string]string{
+				"a/main.tf": tfBackend("a") + tfRemoteState("b", "b") + tfOutput("x", "data.terraform_remote_state.b.outputs.y"),
+				"b/main.tf": tfBackend("b") + tfRemoteState("a", "a") + tfOutput("y", "data.terraform_remote_state.a.outputs.x"),
+			},
+			want: []string{"a/ a/main.tf:21 a/, b/", "b/ b/main.tf:21 a/, b/"},
+		},
+	})
+}
+
+func TestManualReferenceToUnknownOutput(t *testing.T) {
+	files := map[string]string{
+		"net/main.tf":      tfBackend("net") + tfOutput("vpc_id", `"vpc"`),
+		"dns/main.tf":      tfBackend("dns"),
+		"dns/PreManual.md": "Check {{net.nope}} and {{net.gone}}.\n",
+	}
+	dir, cleanup := tempFixture(t, files)
+	defer cleanup()
+	defer chdir(t, dir)()
+
+	workspaces, err := GetWorkspaces(".", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"manual-unknown-output dns/", "manual-unknown-output dns/", "unused-output net/"}
+	if got := findingKeys(LintFindings(workspaces)); !equalStrings(got, want) {
+		t.Errorf("findings are %q, want %q", got, want)
+	}
+}
+
+func TestLintDuplicateBackend(t *testing.T) {
+	runLintCases(t, lintRuleDuplicateBackend, []lintCase{
+		{
+			name: "distinct keys",
+			files: map[string]string{
+				"net/main.tf": tfBackend("net"),
+				"api/main.tf": tfBackend("api"),
+			},
+			want: []string{},
+		},
+		{
+			name: "shared key",
+			files: map[string]string{
+				"net/main.tf":     tfBackend("net"),
+				"net-old/main.tf": tfBackend("net"),
+				"api/main.tf":     tfBackend("api"),
+			},
+			want: []string{"net-old/ net-old/main.tf:1 state/net", "net/ net/main.tf:1 state/net"},
+		},
+	})
+}
+
+func TestLintMissingBackend(t *testing.T) {
+	runLintCases(t, lintRuleMissingBackend, []lintCase{
+		{
+			name:  "complete backend",
+			files: map[string]string{"net/main.tf": tfBackend("net")},
+			want:  []string{},
+		},
+		{
+			name:  "no backend",
+			files: map[string]string{"net/main.tf": tfOutput("vpc_id", `"vpc"`)},
+			want:  []string{"net/ :0 "},
+		},
+		{
+			name: "backend without region",
+			files: map[string]string{
+				"net/main.tf": "terraform {\n  backend \"s3\" {\n    bucket  = \"state\"\n    key     = \"net\"\n    profile = \"prod\"\n  }\n}\n",
+			},
+			want: []string{"net/ :0 "},
+		},
+	})
+}
+
+func TestLintUnknownRemoteState(t *testing.T) {
+	runLintCases(t, lintRuleUnknownRemoteState, []lintCase{
+		{
+			name: "known",
+			files: map[string]string{
+				"net/main.tf": tfBackend("net") + tfOutput("vpc_id", `"vpc"`),
+				"api/main.tf": tfBackend("api") + tfRemoteState("net", "net") + tfOutput("url", "data.terraform_remote_state.net.outputs.vpc_id"),
+			},
+			want: []string{},
+		},
+		{
+			name: "unknown",
+			files: map[string]string{
+				"net/main.tf": tfBackend("net") + tfOutput("vpc_id", `"vpc"`),
+				"api/main.tf": tfBackend("api") + tfRemoteState("net", "nte") + tfRemoteState("dns", "shared/dns/zone") +
+					tfOutput("url", "data.terraform_remote_state.net.outputs.vpc_id") + tfOutput("zone", "data.terraform_remote_state.dns.outputs.zone"),
+			},
+			want: []string{"api/ api/main.tf:10 net", "api/ api/main.tf:20 dns"},
+		},
+	})
+}
+
+func TestLintExternalStates(t *testing.T) {
+	files := map[string]string{
+		"net/main.tf": tfBackend("net") + tfOutput("vpc_id", `"vpc"`),
+		"api/main.tf": tfBackend("api") + tfRemoteState("net", "nte") + tfRemoteState("dns", "shared/dns/zone") +
+			tfOutput("url", "data.terraform_remote_state.net.outputs.vpc_id") + tfOutput("zone", "data.terraform_remote_state.dns.outputs.zone"),
+	}
+	dir, cleanup := tempFixture(t, files)
+	defer cleanup()
+	defer chdir(t, dir)()
+
+	workspaces, err := GetWorkspaces(".", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		cfg        LintConfig
+		suggestion map[string]bool
+	}{
+		{LintConfig{}, map[string]bool{"net": true, "dns": false}},
+		{LintConfig{ExternalStates: []LintExternalState{{Bucket: "state", Key: "shared/*/*"}}}, map[string]bool{"net": true}},
+		{LintConfig{ExternalStates: []LintExternalState{{Key: "nte"}}}, map[string]bool{"dns": false}},
+		{LintConfig{ExternalStates: []LintExternalState{{Bucket: "other", Key: "*"}}}, map[string]bool{"net": true, "dns": false}},
+	}
+	for _, tt := range tests {
+		found := map[string]bool{}
+		for _, f := range LintWorkspaces(workspaces, tt.cfg) {
+			if f.Rule != lintRuleUnknownRemoteState {
+				continue
+			}
+			found[f.Subject] = true
+			suggested := strings.Contains(f.Message, "did you mean bucket 'state' and key 'net' of workspace 'net/'?")
+			if want, ok := tt.suggestion[f.Subject]; !ok || suggested != want {
+				t.Errorf("external states %v: unexpected finding '%s'", tt.cfg.ExternalStates, f.Message)
+			}
+		}
+		if len(found) != len(tt.suggestion) {
+			t.Errorf("external states %v: found unknown remote states %v, want %v", tt.cfg.ExternalStates, found, tt.suggestion)
+		}
+	}
+}
+
+func TestLintBackendDrift(t *testing.T) {
+	drifted := func(profile, region string) string {
+		return fmt.Sprintf(`
+data "terraform_remote_state" "net" {
+  backend = "s3"
+  config {
+    bucket  = "state"
+    key     = "net"
+    profile = "%s"
+    region  = "%s"
+  }
+}
+`, profile, region) + tfOutput("url", "data.terraform_remote_state.net.outputs.vpc_id")
+	}
+
+	runLintCases(t, lintRuleBackendDrift, []lintCase{
+		{
+			name: "matching backend",
+			files: map[string]string{
+				"net/main.tf": tfBackend("net") + tfOutput("vpc_id", `"vpc"`),
+				"api/main.tf": tfBackend("api") + drifted("prod", "eu-west-1"),
+			},
+			want: []string{},
+		},
+		{
+			name: "other profile",
+			files: map[string]string{
+				"net/main.tf": tfBackend("net") + tfOutput("vpc_id", `"vpc"`),
+				"api/main.tf": tfBackend("api") + drifted("dev", "eu-west-1"),
+			},
+			want: []string{"api/ api/main.tf:10 net"},
+		},
+		{
+			name: "other region",
+			files: map[string]string{
+				"net/main.tf": tfBackend("net") + tfOutput("vpc_id", `"vpc"`),
+				"api/main.tf": tfBackend("api") + drifted("prod", "us-east-1"),
+			},
+			want: []string{"api/ api/main.tf:10 net"},
+		},
+		{
+			name: "unknown key",
+			files: map[string]string{
+				"net/main.tf": tfBackend("other") + tfOutput("vpc_id", `"vpc"`),
+				"api/main.tf": tfBackend("api") + drifted("dev", "us-east-1"),
+			},
+			want: []string{},
+		},
+	})
+}
+
+func TestLintManualReferences(t *testing.T) {
+	cycleA := tfBackend("cyc/a") + tfRemoteState("b", "cyc/b") + tfOutput("x", "data.terraform_remote_state.b.outputs.y")
+	cycleB := tfBackend("cyc/b") + tfRemoteState("a", "cyc/a") + tfOutput("y", "data.terraform_remote_state.a.outputs.x")
+	files := map[string]string{
+		"net/main.tf":        tfBackend("net") + tfOutput("vpc_id", `"vpc"`) + "\noutput \"token\" {\n  value     = \"secret\"\n  sensitive = true\n}\n",
+		"apps/api/main.tf":   tfBackend("apps/api") + tfOutput("url", `"api"`),
+		"legacy/api/main.tf": tfBackend("legacy/api") + tfOutput("url", `"legacy"`),
+	}
+	withManuals := func(manuals map[string]string) map[string]string {
+		out := map[string]string{"dns/main.tf": tfBackend("dns") + tfOutput("record", `"dns"`)}
+		for name, content := range files {
+			out[name] = content
+		}
+		for name, content := range manuals {
+			out[name] = content
+		}
+		return out
+	}
+
+	t.Run("unknown", func(t *testing.T) {
+		runLintCases(t, lintRuleManualUnknown, []lintCase{
+			{name: "known", files: withManuals(map[string]string{"dns/PreManual.md": "Use {{net.vpc_id}} and {{apps/api.url}}.\n"}), want: []string{}},
+			{name: "unknown", files: withManuals(map[string]string{"dns/PreManual.md": "Use {{nope.vpc_id}}\nand {{net.nope}}.\n"}), want: []string{"dns/ dns/PreManual.md:1 {{nope.vpc_id}}", "dns/ dns/PreManual.md:2 {{net.nope}}"}},
+		})
+	})
+	t.Run("ambiguous", func(t *testing.T) {
+		runLintCases(t, lintRuleManualAmbiguous, []lintCase{
+			{name: "unique", files: withManuals(map[string]string{"dns/PostManual.md": "Use {{apps/api.url}} and {{legacy/api.url}}.\n"}), want: []string{}},
+			{name: "ambiguous", files: withManuals(map[string]string{"dns/PostManual.md": "Use {{api.url}}.\n"}), want: []string{"dns/ dns/PostManual.md:1 {{api.url}}"}},
+		})
+	})
+	t.Run("malformed", func(t *testing.T) {
+		runLintCases(t, lintRuleManualMalformed, []lintCase{
+			{name: "well-formed", files: withManuals(map[string]string{"dns/PreManual.md": "Use {{ net.vpc_id }}.\n"}), want: []string{}},
+			{name: "malformed", files: withManuals(map[string]string{"dns/PreManual.md": "Use {{net}},\n{{.vpc_id}} and\n{{net.vpc_id\n"}), want: []string{"dns/ dns/PreManual.md:1 {{net}}", "dns/ dns/PreManual.md:2 {{.vpc_id}}", "dns/ dns/PreManual.md:3 {{net.vpc_id"}},
+		})
+	})
+	t.Run("sensitive", func(t *testing.T) {
+		runLintCases(t, lintRuleManualSensitive, []lintCase{
+			{name: "not sensitive", files: withManuals(map[string]string{"dns/PreManual.md": "Use {{net.vpc_id}}.\n"}), want: []string{}},
+			{name: "sensitive", files: withManuals(map[string]string{"dns/PreManual.md": "Use {{net.token}}.\n"}), want: []string{"dns/ dns/PreManual.md:1 {{net.token}}"}},
+		})
+	})
+	t.Run("forward", func(t *testing.T) {
+		runLintCases(t, lintRuleManualForwardRef, []lintCase{
+			{name: "upstream", files: withManuals(map[string]string{"dns/PreManual.md": "Use {{net.vpc_id}}.\n"}), want: []string{}},
+			{name: "own output after apply", files: withManuals(map[string]string{"dns/PostManual.md": "Check {{dns.record}}.\n"}), want: []string{}},
+			{name: "own output before apply", files: withManuals(map[string]string{"dns/PreManual.md": "Check {{dns.record}}.\n"}), want: []string{"dns/ dns/PreManual.md:1 {{dns.record}}"}},
+			{name: "workspace in a cycle", files: withManuals(map[string]string{"dns/PreManual.md": "Check {{cyc/a.x}}.\n", "cyc/a/main.tf": cycleA, "cyc/b/main.tf": cycleB}), want: []string{}},
+		})
+	})
+	t.Run("unplanned", func(t *testing.T) {
+		runLintCases(t, lintRuleManualUnplanned, []lintCase{
+			{name: "planned", files: withManuals(map[string]string{"dns/PreManual.md": "Use {{net.vpc_id}}.\n"}), want: []string{}},
+			{name: "workspace in a cycle", files: withManuals(map[string]string{"dns/PreManual.md": "Check {{cyc/a.x}}.\n", "cyc/a/main.tf": cycleA, "cyc/b/main.tf": cycleB}), want: []string{"dns/ dns/PreManual.md:1 {{cyc/a.x}}"}},
+			{name: "workspace sharing its backend", files: withManuals(map[string]string{"dns/PreManual.md": "Use {{copy/net.vpc_id}}.\n", "copy/net/main.tf": tfBackend("net") + tfRemoteState("api", "apps/api") + tfOutput("vpc_id", "data.terraform_remote_state.api.outputs.url")}), want: []string{"dns/ dns/PreManual.md:1 {{copy/net.vpc_id}}"}},
+		})
+	})
+}
+
+func TestManualAmbiguousReferenceDependencies(t *testing.T) {
+	files := map[string]string{
+		"apps/api/main.tf":   tfBackend("apps/api") + tfOutput("url", `"api"`),
+		"legacy/api/main.tf": tfBackend("legacy/api") + tfOutput("url", `"legacy"`),
+		"dns/main.tf":        tfBackend("dns"),
+		"dns/PreManual.md":   "Point the record to {{api.url}}.\n",
+	}
+	dir, cleanup := tempFixture(t, files)
+	defer cleanup()
+	defer chdir(t, dir)()
+
+	workspaces, err := GetWorkspaces(".", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := BuildExecutionPlan(workspaceSlice(workspaces), []string{}, func(string) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := planRoots(plan), []string{"apps/api/ legacy/api/", "dns/"}; !equalStrings(got, want) {
+		t.Errorf("ambiguous reference is planned as %q, want %q", got, want)
+	}
+	want := []string{"manual-ambiguous-path dns/"}
+	if got := findingKeys(LintFindings(workspaces)); !equalStrings(got, want) {
+		t.Errorf("findings are %q, want %q", got, want)
+	}
+}
+
+func TestLintExternalStateInputs(t *testing.T) {
+	files := map[string]string{
+		"api/main.tf": tfBackend("api") + tfRemoteState("ext", "shared/dns/zone") +
+			"\nlocals {\n  zone = data.terraform_remote_state.ext.outputs.zone_id\n}\n",
+	}
+	dir, cleanup := tempFixture(t, files)
+	defer cleanup()
+	defer chdir(t, dir)()
+
+	workspaces, err := GetWorkspaces(".", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := LintConfig{ExternalStates: []LintExternalState{{Bucket: "state", Key: "shared/*/*"}}}
+	findings := LintWorkspaces(workspaces, cfg)
+	if len(findings) != 0 {
+		t.Errorf("expected no findings for inputs of external states, got %v", findingKeys(findings))
+	}
+	fails, err := LintFails(findings, lintSeverityError)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fails {
+		t.Error("expected lint not to fail for inputs of external states")
+	}
+
+	if got := findingKeys(LintWorkspaces(workspaces, LintConfig{})); !equalStrings(got, []string{"unknown-remote-state api/", "inexistent-input api/"}) {
+		t.Errorf("expected findings without external states, got %v", got)
+	}
+}
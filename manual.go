@@ -0,0 +1,109 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// manualReferenceRe matches references such as '{{apps/api.url}}' in manuals,
+// the second group is empty if the reference is not closed.
+var manualReferenceRe = regexp.MustCompile(`\{\{([^{}\n]*)(\}\})?`)
+
+// manualReference is a reference to an output of a workspace in a manual.
+type manualReference struct {
+	Match     string
+	InFile    string
+	Line      int
+	Workspace string
+	Output    string
+	Malformed bool
+}
+
+// manualReferences returns all references in the manuals of the workspace,
+// ordered by file and position. References not of the form
+// '{{path/to/workspace.output}}' are marked as malformed.
+func (ws Workspace) manualReferences() []manualReference {
+	refs := []manualReference{}
+	manuals := map[string]Manual{
+		preFileName:  ws.PreManual,
+		postFileName: ws.PostManual,
+	}
+	for _, filename := range []string{preFileName, postFileName} {
+		m := string(manuals[filename])
+		for _, loc := range manualReferenceRe.FindAllStringSubmatchIndex(m, -1) {
+			ref := manualReference{
+				Match:  m[loc[0]:loc[1]],
+				InFile: filename,
+				Line:   strings.Count(m[:loc[0]], "\n") + 1,
+			}
+			seg := strings.SplitN(strings.TrimSpace(m[loc[2]:loc[3]]), ".", 2)
+			if loc[4] < 0 || len(seg) != 2 || seg[0] == "" || seg[1] == "" {
+				ref.Malformed = true
+			} else {
+				ref.Workspace, ref.Output = seg[0], seg[1]
+			}
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// resolve returns the workspace and output a reference points to. Either is
+// nil if it does not exist, candidates lists the workspaces matching an
+// ambiguous path.
+func (ref manualReference) resolve(workspaces map[string]*Workspace) (ws *Workspace, output *Output, candidates []string) {
+	if ref.Malformed {
+		return nil, nil, nil
+	}
+	// prefer workspaces whose path ends with the one referenced
+	path := strings.TrimSuffix(filepath.FromSlash(ref.Workspace), "/")
+	exact := []string{}
+	for root := range workspaces {
+		trimmed := strings.TrimSuffix(root, "/")
+		if trimmed == path || strings.HasSuffix(trimmed, "/"+path) {
+			exact = append(exact, root)
+		}
+		if strings.Contains(root, path) {
+			candidates = append(candidates, root)
+		}
+	}
+	if len(exact) == 1 {
+		candidates = exact
+	}
+	if len(candidates) != 1 {
+		sort.Strings(candidates)
+		return nil, nil, candidates
+	}
+
+	ws = workspaces[candidates[0]]
+	for i := range ws.Outputs {
+		if ws.Outputs[i].Name == ref.Output {
+			return ws, &ws.Outputs[i], nil
+		}
+	}
+	return ws, nil, nil
+}
+
+// targets returns the workspaces a reference resolves to along with the
+// output referenced in each. Ambiguous references resolve to every matching
+// workspace defining the output, which keeps these workspaces planned before
+// the one of the manual until the reference is fixed.
+func (ref manualReference) targets(workspaces map[string]*Workspace) ([]*Workspace, []*Output) {
+	ws, output, candidates := ref.resolve(workspaces)
+	if output != nil {
+		return []*Workspace{ws}, []*Output{output}
+	}
+	targets, outputs := []*Workspace{}, []*Output{}
+	for _, root := range candidates {
+		ws := workspaces[root]
+		for i := range ws.Outputs {
+			if ws.Outputs[i].Name == ref.Output {
+				targets = append(targets, ws)
+				outputs = append(outputs, &ws.Outputs[i])
+			}
+		}
+	}
+	return targets, outputs
+}
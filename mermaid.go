@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+type mermaidExporter struct{}
+
+// Export writes the graph as mermaid flowchart. Workspaces of detailed graphs
+// are drawn as subgraphs containing subgraphs for outputs and inputs.
+func (mermaidExporter) Export(w io.Writer, g *Graph) error {
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	styles := []string{}
+	ids := g.nodeIDs(mermaidID)
+
+	style := func(n *GraphNode) {
+		props := []string{}
+		if n.Fill != "" {
+			props = append(props, "fill:"+n.Fill)
+		}
+		if n.Color != "" {
+			props = append(props, "stroke:"+n.Color)
+			if n.Parent != "" || !g.Detailed {
+				props = append(props, "color:"+n.Color)
+			}
+		}
+		if len(props) > 0 {
+			styles = append(styles, fmt.Sprintf("    style %s %s", ids[n.ID], strings.Join(props, ",")))
+		}
+	}
+
+	node := func(n *GraphNode, indent string) {
+		shape := mermaidShapes[n.Shape]
+		if shape[0] == "" {
+			shape = [2]string{"[", "]"}
+		}
+		fmt.Fprintf(&b, "%s%s%s%s%s\n", indent, ids[n.ID], shape[0], mermaidLabel(n.Label), shape[1])
+		style(n)
+	}
+
+	workspace := func(ws *GraphNode, indent string) {
+		if !g.Detailed {
+			node(ws, indent)
+			return
+		}
+
+		fmt.Fprintf(&b, "%ssubgraph %s[%s]\n", indent, ids[ws.ID], mermaidLabel(ws.Label))
+		for _, kind := range []string{nodeKindOutput, nodeKindInput} {
+			children := []*GraphNode{}
+			for _, child := range g.children(ws.ID) {
+				if child.Kind == kind {
+					children = append(children, child)
+				}
+			}
+			if len(children) == 0 {
+				continue
+			}
+			fmt.Fprintf(&b, "%s    subgraph %s_%ss[%ss]\n", indent, ids[ws.ID], kind, kind)
+			for _, child := range children {
+				node(child, indent+"        ")
+			}
+			fmt.Fprintf(&b, "%s    end\n", indent)
+		}
+		fmt.Fprintf(&b, "%send\n", indent)
+		style(ws)
+	}
+
+	// draw workspaces, grouped by their owner
+	groups := map[string][]*GraphNode{}
+	for _, ws := range g.roots() {
+		if ws.Group == "" {
+			workspace(ws, "    ")
+			continue
+		}
+		groups[ws.Group] = append(groups[ws.Group], ws)
+	}
+	for _, group := range sortedGroupNames(groups) {
+		fmt.Fprintf(&b, "    subgraph %s[%s]\n", "owner_"+mermaidID(group), mermaidLabel(group))
+		for _, ws := range groups[group] {
+			workspace(ws, "        ")
+		}
+		b.WriteString("    end\n")
+	}
+
+	// draw relations/dependencies
+	for i, e := range g.Edges {
+		if e.Label != "" {
+			fmt.Fprintf(&b, "    %s -->|%s| %s\n", ids[e.From], mermaidLabel(e.Label), ids[e.To])
+		} else {
+			fmt.Fprintf(&b, "    %s --> %s\n", ids[e.From], ids[e.To])
+		}
+		if e.Color != "" {
+			styles = append(styles, fmt.Sprintf("    linkStyle %d stroke:%s", i, e.Color))
+		}
+	}
+
+	for _, style := range styles {
+		fmt.Fprintln(&b, style)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var mermaidShapes = map[string][2]string{
+	nodeShapePreManual:  {"[/", "/]"},
+	nodeShapePostManual: {"[\\", "\\]"},
+	nodeShapeManual:     {"{{", "}}"},
+}
+
+// mermaidID turns the id of a graph node into an identifier usable for
+// mermaid nodes. The prefix avoids clashes with keywords such as 'end'.
+func mermaidID(id string) string {
+	return "ws_" + strings.Replace(exportID(id), "-", "_", -1)
+}
+
+// mermaidLabel quotes a label, replacing characters mermaid cannot handle
+// within quoted strings.
+func mermaidLabel(s string) string {
+	return `"` + strings.Replace(s, `"`, "#quot;", -1) + `"`
+}
@@ -2,81 +2,97 @@ package main
 
 import (
 	"fmt"
+	"sort"
 	"strings"
+
+	"github.com/russross/blackfriday"
 )
 
+// BuildExecutionPlan orders the workspaces given in tiers that can be applied
+// one after the other. If roots are given only the workspaces whose root
+// contains one of them and the workspaces depending on those are planned.
+// Workspaces that cannot be planned, e.g. due to circular dependencies, are
+// named in the error returned along with the tiers planned so far.
 func BuildExecutionPlan(workspaces []*Workspace, roots []string, debug func(string)) ([][]*Workspace, error) {
+	selected, err := findRootWorkspaces(workspaces, roots)
+	if err != nil {
+		return [][]*Workspace{}, err
+	}
+	return BuildExecutionPlanFrom(workspaces, selected, debug)
+}
+
+// findRootWorkspaces returns all workspaces whose root contains one of the
+// roots given.
+func findRootWorkspaces(workspaces []*Workspace, roots []string) ([]*Workspace, error) {
+	selected := []*Workspace{}
+	for _, root := range roots {
+		exists := false
+		for _, ws := range workspaces {
+			if strings.Contains(ws.Root, root) {
+				exists = true
+				selected = append(selected, ws)
+			}
+		}
+		if !exists {
+			return selected, fmt.Errorf("Workspace '%s' does not exist", root)
+		}
+	}
+	return selected, nil
+}
+
+// BuildExecutionPlanFrom works like BuildExecutionPlan but takes the root
+// workspaces themselves. If roots is empty all workspaces are planned.
+func BuildExecutionPlanFrom(workspaces []*Workspace, roots []*Workspace, debug func(string)) ([][]*Workspace, error) {
 	plan := [][]*Workspace{}
+	firstTier := []*Workspace{}
 	if len(roots) == 0 {
-		// root are all workspaces which do not depend on anything
+		// root are all workspaces which do not depend on anything but
+		// themselves, as manuals may refer to outputs of their own workspace
 		for _, workspace := range workspaces {
-			if len(workspace.Inputs) == 0 {
-				roots = append(roots, workspace.Root)
+			independent := true
+			for _, input := range workspace.Inputs {
+				if input.ReferesTo == nil || input.ReferesTo.BelongsTo != workspace {
+					independent = false
+				}
+			}
+			if independent {
+				firstTier = append(firstTier, workspace)
 			}
 		}
 	} else {
 		// reduce workspaces to only the ones that depend on the give roots
-		var getRelevantWorkspaces func(*Workspace) []*Workspace
-		getRelevantWorkspaces = func(ws *Workspace) []*Workspace {
-			out := []*Workspace{ws}
-			for _, output := range ws.Outputs {
-				for _, input := range output.ReferedBy {
-					out = append(out, getRelevantWorkspaces(input.BelongsTo)...)
-				}
-			}
-			return out
-		}
-
+		visited := map[*Workspace]bool{}
 		relevantWorkspaces := []*Workspace{}
-		for _, root := range roots {
-			exists := false
-			for _, ws := range workspaces {
-				if strings.Contains(ws.Root, root) {
-					exists = true
-					relevantWorkspaces = append(relevantWorkspaces, getRelevantWorkspaces(ws)...)
-				}
-			}
-			if !exists {
-				err := fmt.Errorf("Workspace '%s' does not exist", root)
-				return plan, err
-			}
+		for _, ws := range roots {
+			relevantWorkspaces = append(relevantWorkspaces, downstreamWorkspaces(ws, visited)...)
 		}
 		workspaces = relevantWorkspaces
 
 		// remove roots that depend on workspaces that are considered
-		newRoots := []string{}
-		for _, root := range roots {
+		seen := map[*Workspace]bool{}
+		for _, ws := range roots {
+			if seen[ws] {
+				continue
+			}
+			seen[ws] = true
 			hasDependencies := false
-			for _, ws := range workspaces {
-				if strings.Contains(ws.Root, root) {
-					for _, input := range ws.Inputs {
-						for _, wrkspce := range workspaces {
-							if input.ReferesTo.BelongsTo.Root == wrkspce.Root {
-								hasDependencies = true
-							}
-						}
-					}
+			for _, input := range ws.Inputs {
+				if input.ReferesTo == nil || input.ReferesTo.BelongsTo == ws {
+					continue
+				}
+				if visited[input.ReferesTo.BelongsTo] {
+					hasDependencies = true
 				}
 			}
 			if !hasDependencies {
-				newRoots = append(newRoots, root)
-			}
-		}
-		roots = newRoots
-	}
-
-	firstTier := []*Workspace{}
-	for _, root := range roots {
-		for _, ws := range workspaces {
-			if strings.Contains(ws.Root, root) {
 				firstTier = append(firstTier, ws)
 			}
 		}
 	}
 	plan = append(plan, firstTier)
 
-	var nextTier func(plan [][]*Workspace, workspaces []*Workspace) [][]*Workspace
-	nextTier = func(plan [][]*Workspace, workspaces []*Workspace) [][]*Workspace {
+	var nextTier func(plan [][]*Workspace, workspaces []*Workspace) ([][]*Workspace, error)
+	nextTier = func(plan [][]*Workspace, workspaces []*Workspace) ([][]*Workspace, error) {
 		// get a list of all workspaces that already have been planned
 		planned := []*Workspace{}
 		for _, i := range plan {
@@ -107,7 +123,7 @@ func BuildExecutionPlan(workspaces []*Workspace, roots []string, debug func(stri
 
 		// exit if all is planned
 		if len(unplanned) < 1 {
-			return plan
+			return plan, nil
 		}
 
 		// plan all unplanned with satisfied dependencies
@@ -154,9 +170,155 @@ func BuildExecutionPlan(workspaces []*Workspace, roots []string, debug func(stri
 			}
 		}
 
+		// stop if nothing can be planned anymore, e.g. due to circular dependencies
+		if len(next) < 1 {
+			roots := []string{}
+			for _, ws := range unplanned {
+				roots = append(roots, ws.Root)
+			}
+			sort.Strings(roots)
+			return plan, fmt.Errorf("Workspaces '%s' could not be planned, check for circular dependencies", strings.Join(roots, "', '"))
+		}
+
 		plan = append(plan, next)
 		return nextTier(plan, workspaces)
 	}
 
-	return nextTier(plan, workspaces), nil
+	return nextTier(plan, workspaces)
+}
+
+// downstreamWorkspaces returns the workspace passed and all workspaces that
+// depend on it directly or indirectly. Workspaces already visited are skipped.
+func downstreamWorkspaces(ws *Workspace, visited map[*Workspace]bool) []*Workspace {
+	if visited[ws] {
+		return []*Workspace{}
+	}
+	visited[ws] = true
+	out := []*Workspace{ws}
+	for _, output := range ws.Outputs {
+		for _, input := range output.ReferedBy {
+			out = append(out, downstreamWorkspaces(input.BelongsTo, visited)...)
+		}
+	}
+	return out
+}
+
+// producers returns the workspaces whose outputs are referred to by inputs of
+// the workspace passed, sorted by their root.
+func producers(ws *Workspace) []*Workspace {
+	out := []*Workspace{}
+	seen := map[*Workspace]bool{ws: true}
+	for _, input := range ws.Inputs {
+		if input.ReferesTo == nil || seen[input.ReferesTo.BelongsTo] {
+			continue
+		}
+		seen[input.ReferesTo.BelongsTo] = true
+		out = append(out, input.ReferesTo.BelongsTo)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Root < out[j].Root })
+	return out
+}
+
+// consumers returns the workspaces with inputs referring to outputs of the
+// workspace passed, sorted by their root.
+func consumers(ws *Workspace) []*Workspace {
+	out := []*Workspace{}
+	seen := map[*Workspace]bool{ws: true}
+	for _, output := range ws.Outputs {
+		for _, input := range output.ReferedBy {
+			if seen[input.BelongsTo] {
+				continue
+			}
+			seen[input.BelongsTo] = true
+			out = append(out, input.BelongsTo)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Root < out[j].Root })
+	return out
+}
+
+// DependencyEdge connects a workspace consuming outputs with the workspace
+// producing them.
+type DependencyEdge struct {
+	Producer *Workspace `json:"-"`
+	Consumer *Workspace `json:"-"`
+	From     string     `json:"from"`
+	To       string     `json:"to"`
+	Outputs  []string   `json:"outputs"`
+}
+
+// dependencyEdges returns one edge per pair of producing and consuming
+// workspace given, sorted by producer and consumer.
+func dependencyEdges(workspaces []*Workspace) []DependencyEdge {
+	edges := []DependencyEdge{}
+	for _, consumer := range workspaces {
+		index := map[*Workspace]int{}
+		for _, input := range consumer.Inputs {
+			if input.ReferesTo == nil || input.ReferesTo.BelongsTo == consumer {
+				continue
+			}
+			producer := input.ReferesTo.BelongsTo
+			i, ok := index[producer]
+			if !ok {
+				i = len(edges)
+				index[producer] = i
+				edges = append(edges, DependencyEdge{
+					Producer: producer,
+					Consumer: consumer,
+					From:     producer.Root,
+					To:       consumer.Root,
+					Outputs:  []string{},
+				})
+			}
+			edges[i].Outputs = uniqueStrings(append(edges[i].Outputs, input.Name))
+		}
+	}
+	for _, e := range edges {
+		sort.Strings(e.Outputs)
+	}
+	sort.SliceStable(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].To < edges[j].To
+	})
+	return edges
+}
+
+// RenderManuals converts the Pre- and PostManuals of all workspaces of the
+// plan to HTML. If substitute is true references to outputs are replaced
+// with their values, which requires 'terraform' to be installed. The markdown
+// the HTML was rendered from is kept as well.
+func RenderManuals(plan [][]*Workspace, substitute bool) error {
+	for tier, workspaces := range plan {
+		for i, ws := range workspaces {
+			if ws.PreManual != "" {
+				manual := string(ws.PreManual)
+				if substitute {
+					var err error
+					manual, err = ws.PreManual.render(ws.Inputs)
+					if err != nil {
+						return err
+					}
+				}
+				x := blackfriday.Run([]byte(manual))
+				plan[tier][i].PreManualMarkdown = manual
+				plan[tier][i].PreManualRendered = string(x)
+			}
+			if ws.PostManual != "" {
+				manual := string(ws.PostManual)
+				if substitute {
+					var err error
+					manual, err = ws.PostManual.render(ws.Inputs)
+					if err != nil {
+						return err
+					}
+				}
+				x := blackfriday.Run([]byte(manual))
+				plan[tier][i].PostManualMarkdown = manual
+				plan[tier][i].PostManualRendered = string(x)
+			}
+		}
+	}
+	return nil
 }
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildExecutionPlan(t *testing.T) {
+	files := map[string]string{
+		"net/main.tf": tfBackend("net") + tfOutput("vpc_id", `"vpc"`),
+		"api/main.tf": tfBackend("api") + tfRemoteState("net", "net") + tfOutput("url", "data.terraform_remote_state.net.outputs.vpc_id"),
+		"web/main.tf": tfBackend("web") + tfRemoteState("api", "api") + tfOutput("host", "data.terraform_remote_state.api.outputs.url"),
+		"a/main.tf":   tfBackend("a") + tfRemoteState("b", "b") + tfOutput("x", "data.terraform_remote_state.b.outputs.y"),
+		"b/main.tf":   tfBackend("b") + tfRemoteState("a", "a") + tfOutput("y", "data.terraform_remote_state.a.outputs.x"),
+		"c/main.tf":   tfBackend("c") + tfRemoteState("a", "a") + tfOutput("z", "data.terraform_remote_state.a.outputs.x"),
+	}
+	dir, cleanup := tempFixture(t, files)
+	defer cleanup()
+	defer chdir(t, dir)()
+
+	workspaces, err := GetWorkspaces(".", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		roots        []string
+		tiers        []string
+		unplanned    []string
+		doesNotExist bool
+	}{
+		{[]string{"net/"}, []string{"net/", "api/", "web/"}, nil, false},
+		{[]string{"api/"}, []string{"api/", "web/"}, nil, false},
+		{[]string{}, []string{"net/", "api/", "web/"}, []string{"a/", "b/", "c/"}, false},
+		{[]string{"a/"}, []string{""}, []string{"a/", "b/", "c/"}, false},
+		{[]string{"nope/"}, []string{}, nil, true},
+	}
+	for _, tt := range tests {
+		plan, err := BuildExecutionPlan(workspaceSlice(workspaces), tt.roots, func(string) {})
+		if got := planRoots(plan); !equalStrings(got, tt.tiers) {
+			t.Errorf("roots %q are planned as %q, want %q", tt.roots, got, tt.tiers)
+		}
+		switch {
+		case tt.doesNotExist:
+			if err == nil {
+				t.Errorf("expected an error planning roots %q", tt.roots)
+			}
+		case len(tt.unplanned) == 0:
+			if err != nil {
+				t.Errorf("planning roots %q failed: %s", tt.roots, err)
+			}
+		case err == nil:
+			t.Errorf("expected an error naming %q planning roots %q", tt.unplanned, tt.roots)
+		default:
+			if !strings.Contains(err.Error(), "'"+strings.Join(tt.unplanned, "', '")+"'") {
+				t.Errorf("error '%s' does not name %q", err, tt.unplanned)
+			}
+		}
+	}
+}
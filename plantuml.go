@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+type plantUMLExporter struct{}
+
+// Export writes the graph as PlantUML diagram. Workspaces are drawn as
+// rectangles stereotyped with <<workspace>>, or with the kind of manuals they
+// carry. Outputs and inputs of detailed graphs are nested, workspaces are
+// grouped into packages by their owner.
+func (plantUMLExporter) Export(w io.Writer, g *Graph) error {
+	var b strings.Builder
+	b.WriteString("@startuml\n")
+	b.WriteString("left to right direction\n")
+	ids := g.nodeIDs(plantUMLID)
+
+	node := func(n *GraphNode, indent string, open bool) {
+		stereotype := n.Kind
+		if n.Shape != "" {
+			stereotype = n.Shape
+		}
+		fmt.Fprintf(&b, "%srectangle %s as %s <<%s>>", indent, plantUMLLabel(n.Label), ids[n.ID], stereotype)
+		colors := []string{}
+		if n.Fill != "" {
+			colors = append(colors, n.Fill)
+		}
+		if n.Color != "" {
+			colors = append(colors, "line:"+n.Color)
+		}
+		if len(colors) > 0 {
+			fmt.Fprintf(&b, " #%s", strings.TrimPrefix(strings.Join(colors, ";"), "#"))
+		}
+		if open {
+			b.WriteString(" {")
+		}
+		b.WriteString("\n")
+	}
+
+	workspace := func(ws *GraphNode, indent string) {
+		children := g.children(ws.ID)
+		if len(children) == 0 {
+			node(ws, indent, false)
+			return
+		}
+		node(ws, indent, true)
+		for _, child := range children {
+			node(child, indent+"  ", false)
+		}
+		b.WriteString(indent + "}\n")
+	}
+
+	// draw workspaces, grouped by their owner
+	groups := map[string][]*GraphNode{}
+	for _, ws := range g.roots() {
+		if ws.Group == "" {
+			workspace(ws, "")
+			continue
+		}
+		groups[ws.Group] = append(groups[ws.Group], ws)
+	}
+	for _, group := range sortedGroupNames(groups) {
+		fmt.Fprintf(&b, "package %s {\n", plantUMLLabel(group))
+		for _, ws := range groups[group] {
+			workspace(ws, "  ")
+		}
+		b.WriteString("}\n")
+	}
+
+	// draw relations/dependencies
+	for _, e := range g.Edges {
+		arrow := "-->"
+		if e.Color != "" {
+			arrow = "-[#" + e.Color + "]->"
+		}
+		fmt.Fprintf(&b, "%s %s %s", ids[e.From], arrow, ids[e.To])
+		if e.Label != "" {
+			fmt.Fprintf(&b, " : %s", e.Label)
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("@enduml\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func plantUMLID(id string) string {
+	return strings.Replace(exportID(id), "-", "_", -1)
+}
+
+func plantUMLLabel(s string) string {
+	return `"` + strings.Replace(s, `"`, `'`, -1) + `"`
+}
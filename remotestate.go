@@ -18,3 +18,8 @@ func (orig RemoteState) equals(other RemoteState) bool {
 	}
 	return false
 }
+
+// isEmpty returns true if no backend could be resolved.
+func (orig RemoteState) isEmpty() bool {
+	return orig.equals(RemoteState{})
+}
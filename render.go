@@ -2,20 +2,252 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
 	"text/template"
+	"text/template/parse"
+	"time"
+	"unicode"
+
+	"github.com/russross/blackfriday"
 )
 
+// TemplateContext is passed to templates rendering an execution plan.
+// GeneratedAt is the zero time unless the caller asks for a timestamp.
+// Anchors maps the roots of the workspaces in the plan to identifiers unique
+// among them, usable as HTML ids and link targets.
+type TemplateContext struct {
+	Tiers       [][]*Workspace
+	Workspaces  []*Workspace
+	Edges       []DependencyEdge
+	Anchors     map[string]string
+	GeneratedAt time.Time
+	BaseDir     string
+	Version     string
+}
+
+func NewTemplateContext(plan [][]*Workspace, baseDir string, generatedAt time.Time) TemplateContext {
+	workspaces := flattenPlan(plan)
+	roots := []string{}
+	for _, ws := range workspaces {
+		roots = append(roots, ws.Root)
+	}
+	v := version
+	if v == "" {
+		v = "dirty"
+	}
+	return TemplateContext{
+		Tiers:       plan,
+		Workspaces:  workspaces,
+		Edges:       dependencyEdges(workspaces),
+		Anchors:     exportIDs(roots),
+		GeneratedAt: generatedAt,
+		BaseDir:     baseDir,
+		Version:     v,
+	}
+}
+
 func GetTemplateFuncMap() template.FuncMap {
 	funcMap := template.FuncMap{
+		// numbers
 		"mod": func(i, j int) bool { return i%j == 0 },
+		"add": func(i, j int) int { return i + j },
+		"sub": func(i, j int) int { return i - j },
+
+		// strings
+		"lower":      strings.ToLower,
+		"upper":      strings.ToUpper,
+		"title":      title,
+		"trim":       strings.TrimSpace,
+		"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+		"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+		"replace":    func(old, new, s string) string { return strings.Replace(s, old, new, -1) },
+		"contains":   func(substr, s string) bool { return strings.Contains(s, substr) },
+		"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+		"hasSuffix":  func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+		"split":      func(sep, s string) []string { return strings.Split(s, sep) },
+		"join":       func(sep string, s []string) string { return strings.Join(s, sep) },
+		"repeat":     func(count int, s string) string { return strings.Repeat(s, count) },
+		"indent":     func(spaces int, s string) string { return indentLines(s, strings.Repeat(" ", spaces)) },
+		"quote":      func(s string) string { return fmt.Sprintf("%q", s) },
+
+		// lists
+		"list":  func(items ...interface{}) []interface{} { return items },
+		"first": first,
+		"last":  last,
+		"seq":   seq,
+
+		// paths
+		"base":  filepath.Base,
+		"dir":   filepath.Dir,
+		"ext":   filepath.Ext,
+		"clean": filepath.Clean,
+		"path":  filepath.Join,
+		"rel":   relPath,
+
+		// markdown
+		"markdown": func(s string) string { return string(blackfriday.Run([]byte(s))) },
+		"mdEscape": markdownEscape,
+		"anchor":   anchor,
+
+		// json
+		"toJSON":       toJSON,
+		"toPrettyJSON": toPrettyJSON,
+
+		// workspaces
+		"dependsOn": producers,
+		"consumers": consumers,
+		"outputsOf": func(ws *Workspace) []Output { return ws.Outputs },
 	}
 	return funcMap
 }
 
-func RenderExecutionPlan(plan [][]*Workspace, tmplSrc string) string {
+func RenderExecutionPlan(ctx TemplateContext, tmplSrc string) (string, error) {
 	var out bytes.Buffer
-	tmpl := template.Must(template.New("test").Funcs(GetTemplateFuncMap()).Parse(tmplSrc))
-	tmpl.Execute(&out, plan)
+	tmpl, err := template.New("plan").Funcs(GetTemplateFuncMap()).Parse(tmplSrc)
+	if err != nil {
+		return "", fmt.Errorf("could not parse template: %s", err.Error())
+	}
+	if rangesOverDot(tmpl) {
+		return "", fmt.Errorf("could not render template: '.' is no longer the list of tiers, use 'range .Tiers' instead of 'range .'")
+	}
+	err = tmpl.Execute(&out, ctx)
+	if err != nil {
+		return out.String(), fmt.Errorf("could not render template: %s", err.Error())
+	}
+	return out.String(), nil
+}
+
+// rangesOverDot returns true if the template iterates over the context passed
+// to it, as templates did when the context was the plan. The whole parse tree
+// is inspected, including templates invoked with the context, while the
+// bodies of 'range' and 'with' are skipped if they rebind '.' to something
+// else.
+func rangesOverDot(tmpl *template.Template) bool {
+	visited := map[string]bool{}
+	// dot and root tell whether '.' and '$' refer to the context
+	var walk func(node parse.Node, dot, root bool) bool
+	walk = func(node parse.Node, dot, root bool) bool {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return false
+			}
+			for _, child := range n.Nodes {
+				if walk(child, dot, root) {
+					return true
+				}
+			}
+		case *parse.IfNode:
+			return walk(n.List, dot, root) || walk(n.ElseList, dot, root)
+		case *parse.RangeNode:
+			if isContext(n.Pipe, dot, root) {
+				return true
+			}
+			return walk(n.List, false, root) || walk(n.ElseList, dot, root)
+		case *parse.WithNode:
+			return walk(n.List, isContext(n.Pipe, dot, root), root) || walk(n.ElseList, dot, root)
+		case *parse.TemplateNode:
+			if !isContext(n.Pipe, dot, root) || visited[n.Name] {
+				return false
+			}
+			visited[n.Name] = true
+			t := tmpl.Lookup(n.Name)
+			return t != nil && t.Tree != nil && walk(t.Tree.Root, true, true)
+		}
+		return false
+	}
+	return walk(tmpl.Tree.Root, true, true)
+}
+
+// isContext returns true if the pipeline given evaluates to the context
+// passed to the template, i.e. is '.' or '$' while they refer to it.
+func isContext(pipe *parse.PipeNode, dot, root bool) bool {
+	if pipe == nil || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return false
+	}
+	switch arg := pipe.Cmds[0].Args[0].(type) {
+	case *parse.DotNode:
+		return dot
+	case *parse.VariableNode:
+		return root && len(arg.Ident) == 1 && arg.Ident[0] == "$"
+	}
+	return false
+}
+
+func first(list interface{}) (interface{}, error) {
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("first: %s is not a list", v.Kind())
+	}
+	if v.Len() == 0 {
+		return nil, nil
+	}
+	return v.Index(0).Interface(), nil
+}
+
+func last(list interface{}) (interface{}, error) {
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("last: %s is not a list", v.Kind())
+	}
+	if v.Len() == 0 {
+		return nil, nil
+	}
+	return v.Index(v.Len() - 1).Interface(), nil
+}
+
+// seq returns the numbers from 1 to n.
+func seq(n int) []int {
+	out := []int{}
+	for i := 1; i <= n; i++ {
+		out = append(out, i)
+	}
+	return out
+}
+
+func relPath(basepath, targpath string) (string, error) {
+	return filepath.Rel(basepath, targpath)
+}
+
+// title returns s with the first letter of each word in title case. Words are
+// separated by anything but letters, digits and underscores.
+func title(s string) string {
+	prev := ' '
+	return strings.Map(func(r rune) rune {
+		start := !unicode.IsLetter(prev) && !unicode.IsDigit(prev) && prev != '_'
+		prev = r
+		if start {
+			return unicode.ToTitle(r)
+		}
+		return r
+	}, s)
+}
+
+var markdownSpecialChars = regexp.MustCompile("([\\\\`*_{}\\[\\]()#+\\-.!|<>])")
+
+func markdownEscape(s string) string {
+	return markdownSpecialChars.ReplaceAllString(s, `\$1`)
+}
+
+var nonAnchorChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// anchor returns a string usable as link target within HTML and markdown
+// documents.
+func anchor(s string) string {
+	return strings.Trim(nonAnchorChars.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}
+
+func toJSON(v interface{}) (string, error) {
+	out, err := json.Marshal(v)
+	return string(out), err
+}
 
-	return out.String()
+func toPrettyJSON(v interface{}) (string, error) {
+	out, err := json.MarshalIndent(v, "", "    ")
+	return string(out), err
 }
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	stepPreManual  = "pre_manual"
+	stepCommand    = "command"
+	stepPostManual = "post_manual"
+
+	statusDone    = "done"
+	statusSkipped = "skipped"
+	statusFailed  = "failed"
+)
+
+// ManualPolicy defines how manual steps are confirmed while running across
+// the execution plan. An empty policy asks for confirmation on the terminal.
+type ManualPolicy string
+
+const (
+	ManualPolicyInteractive ManualPolicy = ""
+	ManualPolicyFail        ManualPolicy = "fail"
+	ManualPolicySkip        ManualPolicy = "skip"
+	ManualPolicyDone        ManualPolicy = "done"
+)
+
+func NewManualPolicy(s string) (ManualPolicy, error) {
+	p := ManualPolicy(s)
+	switch p {
+	case ManualPolicyInteractive, ManualPolicyFail, ManualPolicySkip, ManualPolicyDone:
+		return p, nil
+	}
+	return p, fmt.Errorf("manual policy '%s' is not valid, use one of 'fail', 'skip' or 'done'", s)
+}
+
+// RunLogEntry is written to the run log as a single JSON line for each step
+// executed by the Runner.
+type RunLogEntry struct {
+	Time      time.Time `json:"time"`
+	Workspace string    `json:"workspace"`
+	Step      string    `json:"step"`
+	Status    string    `json:"status"`
+	Answer    string    `json:"answer,omitempty"`
+	Duration  float64   `json:"duration_seconds,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Runner executes a command in each workspace of an execution plan and pauses
+// before workspaces with a PreManual and after workspaces with a PostManual.
+type Runner struct {
+	Command []string
+	Policy  ManualPolicy
+	In      *bufio.Reader
+	Out     io.Writer
+	Log     io.Writer
+	debug   func(string)
+}
+
+func NewRunner(command []string, policy ManualPolicy, log io.Writer, debug func(string)) *Runner {
+	return &Runner{
+		Command: command,
+		Policy:  policy,
+		In:      bufio.NewReader(os.Stdin),
+		Out:     os.Stdout,
+		Log:     log,
+		debug:   debug,
+	}
+}
+
+// Run executes the plan tier by tier and stops at the first failure. Skipping
+// a PreManual skips the workspace as well as all workspaces depending on it.
+func (r *Runner) Run(plan [][]*Workspace) error {
+	skipped := map[*Workspace]bool{}
+	for tier, workspaces := range plan {
+		for _, ws := range workspaces {
+			r.debug(fmt.Sprintf("Running workspace %s of tier %d\n", ws.Root, tier))
+
+			if p := skippedProducer(ws, skipped); p != nil {
+				fmt.Fprintf(r.Out, "\n=== %s: skipped, depends on skipped workspace '%s'\n", ws.Root, p.Root)
+				r.log(RunLogEntry{Workspace: ws.Root, Step: stepCommand, Status: statusSkipped, Error: fmt.Sprintf("depends on skipped workspace '%s'", p.Root)})
+				skipped[ws] = true
+				continue
+			}
+
+			if ws.PreManual != "" {
+				status, err := r.manual(ws, stepPreManual, ws.PreManual)
+				if err != nil {
+					return err
+				}
+				if status == statusSkipped {
+					r.log(RunLogEntry{Workspace: ws.Root, Step: stepCommand, Status: statusSkipped})
+					skipped[ws] = true
+					continue
+				}
+			}
+
+			err := r.command(ws)
+			if err != nil {
+				return err
+			}
+
+			if ws.PostManual != "" {
+				_, err := r.manual(ws, stepPostManual, ws.PostManual)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// skippedProducer returns a skipped workspace the workspace given depends on
+// or nil if there is none.
+func skippedProducer(ws *Workspace, skipped map[*Workspace]bool) *Workspace {
+	for _, input := range ws.Inputs {
+		if input.ReferesTo != nil && skipped[input.ReferesTo.BelongsTo] {
+			return input.ReferesTo.BelongsTo
+		}
+	}
+	return nil
+}
+
+func (r *Runner) command(ws *Workspace) error {
+	fmt.Fprintf(r.Out, "\n=== %s: %s\n", ws.Root, strings.Join(r.Command, " "))
+
+	cmd := exec.Command(r.Command[0], r.Command[1:]...)
+	cmd.Dir = ws.Root
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = r.Out
+	cmd.Stderr = os.Stderr
+
+	start := time.Now()
+	err := cmd.Run()
+	entry := RunLogEntry{
+		Workspace: ws.Root,
+		Step:      stepCommand,
+		Status:    statusDone,
+		Duration:  time.Since(start).Seconds(),
+	}
+	if err != nil {
+		err = fmt.Errorf("could not run command '%s' in '%s': %s", strings.Join(r.Command, " "), ws.Root, err.Error())
+		entry.Status = statusFailed
+		entry.Error = err.Error()
+	}
+	r.log(entry)
+	return err
+}
+
+func (r *Runner) manual(ws *Workspace, step string, m Manual) (string, error) {
+	entry := RunLogEntry{Workspace: ws.Root, Step: step}
+
+	rendered, err := m.render(ws.Inputs)
+	if err != nil {
+		entry.Status = statusFailed
+		entry.Error = err.Error()
+		r.log(entry)
+		return entry.Status, err
+	}
+
+	fmt.Fprintf(r.Out, "\n=== %s: %s\n\n%s\n", ws.Root, strings.Replace(step, "_", " ", -1), strings.TrimSpace(rendered))
+
+	switch r.Policy {
+	case ManualPolicyInteractive:
+		entry.Answer, entry.Status = r.ask(ws, step)
+	case ManualPolicySkip:
+		entry.Answer, entry.Status = string(r.Policy), statusSkipped
+	case ManualPolicyDone:
+		entry.Answer, entry.Status = string(r.Policy), statusDone
+	default:
+		entry.Answer, entry.Status = string(r.Policy), statusFailed
+	}
+
+	if entry.Status == statusFailed {
+		err = fmt.Errorf("manual step '%s' of workspace '%s' was not confirmed", step, ws.Root)
+		entry.Error = err.Error()
+	}
+	r.log(entry)
+	return entry.Status, err
+}
+
+func (r *Runner) ask(ws *Workspace, step string) (string, string) {
+	for {
+		fmt.Fprintf(r.Out, "\nIs the %s of '%s' done? [y]es, [s]kip, [n]o (abort): ", strings.Replace(step, "_", " ", -1), ws.Root)
+		line, err := r.In.ReadString('\n')
+		answer := strings.ToLower(strings.TrimSpace(line))
+		switch answer {
+		case "y", "yes":
+			return answer, statusDone
+		case "s", "skip":
+			return answer, statusSkipped
+		case "n", "no":
+			return answer, statusFailed
+		}
+		if err != nil {
+			return answer, statusFailed
+		}
+	}
+}
+
+func (r *Runner) log(entry RunLogEntry) {
+	if r.Log == nil {
+		return
+	}
+	entry.Time = time.Now()
+	out, err := json.Marshal(entry)
+	if err != nil {
+		r.debug(fmt.Sprintf("could not marshal run log entry: %s\n", err.Error()))
+		return
+	}
+	fmt.Fprintln(r.Log, string(out))
+}
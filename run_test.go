@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runPlan returns a plan of the workspaces net <- api <- web and dns in
+// directories below dir. net has a PreManual and web a PostManual.
+func runPlan(dir string) [][]*Workspace {
+	ws := func(name string) *Workspace {
+		return &Workspace{Root: filepath.Join(dir, name) + string(filepath.Separator), Outputs: []Output{{Name: "out"}}}
+	}
+	net, api, web, dns := ws("net"), ws("api"), ws("web"), ws("dns")
+	net.PreManual = "Create the VPC.\n"
+	web.PostManual = "Check the site.\n"
+	for _, link := range [][2]*Workspace{{api, net}, {web, api}} {
+		consumer, producer := link[0], link[1]
+		producer.Outputs[0].BelongsTo = producer
+		consumer.Inputs = append(consumer.Inputs, Input{Name: "out", FullName: "data.terraform_remote_state.x.outputs.out", ReferesTo: &producer.Outputs[0], BelongsTo: consumer})
+	}
+	return [][]*Workspace{{dns, net}, {api}, {web}}
+}
+
+func TestRunner(t *testing.T) {
+	for _, command := range []string{"true", "false"} {
+		if _, err := exec.LookPath(command); err != nil {
+			t.Skipf("%s is not installed", command)
+		}
+	}
+	dir, err := ioutil.TempDir("", "solaris-run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"net", "api", "web", "dns"} {
+		if err := os.Mkdir(filepath.Join(dir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		policy  ManualPolicy
+		input   string
+		command string
+		log     []string
+		err     bool
+	}{
+		{
+			name: "manuals done", policy: ManualPolicyDone, command: "true",
+			log: []string{"dns command done", "net pre_manual done", "net command done", "api command done", "web command done", "web post_manual done"},
+		},
+		{
+			name: "manuals skipped", policy: ManualPolicySkip, command: "true",
+			log: []string{"dns command done", "net pre_manual skipped", "net command skipped", "api command skipped", "web command skipped"},
+		},
+		{
+			name: "manuals fail", policy: ManualPolicyFail, command: "true",
+			log: []string{"dns command done", "net pre_manual failed"}, err: true,
+		},
+		{
+			name: "command fails", policy: ManualPolicyDone, command: "false",
+			log: []string{"dns command failed"}, err: true,
+		},
+		{
+			name: "confirmed", input: "y\nyes\n", command: "true",
+			log: []string{"dns command done", "net pre_manual done", "net command done", "api command done", "web command done", "web post_manual done"},
+		},
+		{
+			name: "skipped", input: "s\n", command: "true",
+			log: []string{"dns command done", "net pre_manual skipped", "net command skipped", "api command skipped", "web command skipped"},
+		},
+		{
+			name: "aborted", input: "n\n", command: "true",
+			log: []string{"dns command done", "net pre_manual failed"}, err: true,
+		},
+		{
+			name: "asked again", input: "maybe\n\ny\nY", command: "true",
+			log: []string{"dns command done", "net pre_manual done", "net command done", "api command done", "web command done", "web post_manual done"},
+		},
+		{
+			name: "end of input", input: "y\n", command: "true",
+			log: []string{"dns command done", "net pre_manual done", "net command done", "api command done", "web command done", "web post_manual failed"}, err: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out, log bytes.Buffer
+			r := NewRunner([]string{tt.command}, tt.policy, &log, func(string) {})
+			r.In = bufio.NewReader(strings.NewReader(tt.input))
+			r.Out = &out
+
+			err := r.Run(runPlan(dir))
+			if tt.err != (err != nil) {
+				t.Errorf("expected error %t, got %v", tt.err, err)
+			}
+
+			got := []string{}
+			for _, line := range strings.Split(strings.TrimSpace(log.String()), "\n") {
+				entry := RunLogEntry{}
+				if err := json.Unmarshal([]byte(line), &entry); err != nil {
+					t.Fatalf("run log line '%s' is not valid JSON: %s", line, err)
+				}
+				if entry.Time.IsZero() {
+					t.Errorf("run log line '%s' has no time", line)
+				}
+				if entry.Status == statusFailed && entry.Error == "" {
+					t.Errorf("run log line '%s' has no error", line)
+				}
+				rel, _ := filepath.Rel(dir, entry.Workspace)
+				got = append(got, strings.Join([]string{filepath.ToSlash(rel), entry.Step, entry.Status}, " "))
+			}
+			if !equalStrings(got, tt.log) {
+				t.Errorf("run log is %q, want %q", got, tt.log)
+			}
+		})
+	}
+}
+
+func TestNewManualPolicy(t *testing.T) {
+	for _, s := range []string{"", "fail", "skip", "done"} {
+		if _, err := NewManualPolicy(s); err != nil {
+			t.Errorf("policy '%s' is not accepted: %s", s, err)
+		}
+	}
+	if _, err := NewManualPolicy("ask"); err == nil {
+		t.Error("expected an error for policy 'ask'")
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ExportMakefile writes a Makefile with one phony target per workspace of the
+// plan. Each target depends on the targets of the workspaces it consumes
+// outputs of, which allows to execute independent workspaces with 'make -j'.
+// Targets are named by the export ids of the workspace roots.
+func ExportMakefile(w io.Writer, plan [][]*Workspace, command string) {
+	workspaces := flattenPlan(plan)
+	roots := []string{}
+	for _, ws := range workspaces {
+		roots = append(roots, ws.Root)
+	}
+	ids := exportIDs(roots, "all")
+	inPlan := map[*Workspace]bool{}
+	targets := []string{}
+	for _, ws := range workspaces {
+		inPlan[ws] = true
+		targets = append(targets, ids[ws.Root])
+	}
+
+	fmt.Fprintf(w, "# generated by solaris, do not edit\n\n")
+	fmt.Fprintf(w, ".PHONY: all %s\n\n", strings.Join(targets, " "))
+	fmt.Fprintf(w, "all: %s\n", strings.Join(targets, " "))
+	for _, ws := range workspaces {
+		deps := []string{}
+		for _, p := range producers(ws) {
+			if inPlan[p] {
+				deps = append(deps, ids[p.Root])
+			}
+		}
+
+		fmt.Fprintf(w, "\n")
+		if ws.PreManual != "" {
+			fmt.Fprintf(w, "# %s requires manual steps before execution, see %s\n", exportDir(ws.Root), ws.Root+preFileName)
+		}
+		if ws.PostManual != "" {
+			fmt.Fprintf(w, "# %s requires manual steps after execution, see %s\n", exportDir(ws.Root), ws.Root+postFileName)
+		}
+		fmt.Fprintf(w, "%s:", ids[ws.Root])
+		if len(deps) > 0 {
+			fmt.Fprintf(w, " %s", strings.Join(deps, " "))
+		}
+		fmt.Fprintf(w, "\n")
+		fmt.Fprintf(w, "\tcd %s && %s\n", shellQuote(exportDir(ws.Root)), strings.Replace(command, "$", "$$", -1))
+	}
+}
+
+// ExportShell writes a POSIX shell script executing the workspaces of the plan
+// one after the other. Manuals are printed and have to be confirmed before the
+// script continues.
+func ExportShell(w io.Writer, plan [][]*Workspace, command string) {
+	fmt.Fprintf(w, "#!/bin/sh\n")
+	fmt.Fprintf(w, "# generated by solaris, do not edit\n")
+	fmt.Fprintf(w, "set -e\n\n")
+	fmt.Fprintf(w, "confirm() {\n")
+	fmt.Fprintf(w, "\tprintf '%%s [y/N] ' \"$1\"\n")
+	fmt.Fprintf(w, "\tread -r answer\n")
+	fmt.Fprintf(w, "\tcase \"$answer\" in\n")
+	fmt.Fprintf(w, "\ty | Y | yes) ;;\n")
+	fmt.Fprintf(w, "\t*)\n")
+	fmt.Fprintf(w, "\t\techo 'aborted' >&2\n")
+	fmt.Fprintf(w, "\t\texit 1\n")
+	fmt.Fprintf(w, "\t\t;;\n")
+	fmt.Fprintf(w, "\tesac\n")
+	fmt.Fprintf(w, "}\n")
+
+	for tier, workspaces := range plan {
+		fmt.Fprintf(w, "\n# --- tier %d ---\n", tier+1)
+		for _, ws := range flattenPlan([][]*Workspace{workspaces}) {
+			dir := exportDir(ws.Root)
+			deps := []string{}
+			for _, p := range producers(ws) {
+				deps = append(deps, exportDir(p.Root))
+			}
+
+			fmt.Fprintf(w, "\n# %s\n", dir)
+			if len(deps) > 0 {
+				fmt.Fprintf(w, "# depends on %s\n", strings.Join(deps, ", "))
+			}
+			if ws.PreManual != "" {
+				writeShellManual(w, ws, "pre manual", ws.PreManual)
+			}
+			fmt.Fprintf(w, "echo %s\n", shellQuote("=== "+dir))
+			fmt.Fprintf(w, "(cd %s && %s)\n", shellQuote(dir), command)
+			if ws.PostManual != "" {
+				writeShellManual(w, ws, "post manual", ws.PostManual)
+			}
+		}
+	}
+}
+
+func writeShellManual(w io.Writer, ws *Workspace, step string, m Manual) {
+	dir := exportDir(ws.Root)
+	fmt.Fprintf(w, "echo %s\n", shellQuote("=== "+dir+": "+step))
+	fmt.Fprintf(w, "printf '%%s\\n' %s\n", shellQuote(strings.TrimRight(string(m), "\n")))
+	fmt.Fprintf(w, "confirm %s\n", shellQuote("Is the "+step+" of "+dir+" done?"))
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// scriptFixture contains a top-level workspace, workspaces whose export ids
+// collide and a workspace with a space in its root and manuals that must not
+// be evaluated by the shell.
+var scriptFixture = map[string]string{
+	"main.tf":              tfBackend("top") + tfOutput("account", `"123"`),
+	"apps/api/main.tf":     tfBackend("api") + tfRemoteState("top", "top") + tfOutput("url", "data.terraform_remote_state.top.outputs.account"),
+	"apps-api/main.tf":     tfBackend("apps-api") + tfOutput("url", `"legacy"`),
+	"my app/main.tf":       tfBackend("app") + tfRemoteState("api", "api") + tfRemoteState("legacy", "apps-api") + tfOutput("urls", "[data.terraform_remote_state.api.outputs.url, data.terraform_remote_state.legacy.outputs.url]"),
+	"my app/PreManual.md":  "Don't apply before `date` is past $DEADLINE\n",
+	"my app/PostManual.md": "Announce it's done\n",
+}
+
+func TestExportScripts(t *testing.T) {
+	testdata, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, cleanup := tempFixture(t, scriptFixture)
+	defer cleanup()
+	defer chdir(t, dir)()
+
+	workspaces, err := GetWorkspaces(".", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := BuildExecutionPlan(workspaceSlice(workspaces), []string{}, func(string) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		golden string
+		export func(io.Writer, [][]*Workspace, string)
+	}{
+		{"export.mk", ExportMakefile},
+		{"export.sh", ExportShell},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		tt.export(&out, plan, `terraform apply -var "home=$HOME"`)
+		assertGolden(t, filepath.Join(testdata, tt.golden), out.Bytes())
+	}
+}
+
+// assertGolden compares got to the content of the golden file at path, which
+// is overwritten instead if the tests run with -update.
+func assertGolden(t *testing.T, path string, got []byte) {
+	t.Helper()
+	if *update {
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s:\n%s", path, got)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// fileSource provides the files workspaces are discovered from.
+type fileSource interface {
+	// walk calls fn with the path of every file and directory below root.
+	walk(root string, fn func(path string) error) error
+	readFile(path string) ([]byte, error)
+	exists(path string) bool
+}
+
+// localFiles reads files from the local file system.
+type localFiles struct{}
+
+func (localFiles) walk(root string, fn func(path string) error) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		return fn(path)
+	})
+}
+
+func (localFiles) readFile(path string) ([]byte, error) {
+	return ioutil.ReadFile(path)
+}
+
+func (localFiles) exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// gitFiles reads files as of a git revision from the repository containing
+// root.
+type gitFiles struct {
+	root  string
+	ref   string
+	files map[string]bool
+}
+
+func newGitFiles(root, ref string) (*gitFiles, error) {
+	src := &gitFiles{root: root, ref: ref, files: map[string]bool{}}
+
+	// ls-tree lists the files below the working directory relative to it
+	out, err := git(root, "ls-tree", "-r", "--name-only", ref)
+	if err != nil {
+		return src, err
+	}
+	for _, f := range gitLines(out) {
+		src.files[filepath.Join(root, filepath.FromSlash(f))] = true
+	}
+	return src, nil
+}
+
+func (g *gitFiles) walk(root string, fn func(path string) error) error {
+	paths := []string{}
+	for path := range g.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if err := fn(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *gitFiles) readFile(path string) ([]byte, error) {
+	if !g.exists(path) {
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+	}
+	rel, err := filepath.Rel(g.root, filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	return git(g.root, "show", g.ref+":./"+filepath.ToSlash(rel))
+}
+
+func (g *gitFiles) exists(path string) bool {
+	return g.files[filepath.Clean(path)]
+}
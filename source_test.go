@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitFiles(t *testing.T) {
+	dir, cleanup := gitFixture(t, map[string]string{
+		"README.md":            "top",
+		"infra/net/main.tf":    "committed",
+		"infra/my app/main.tf": "spaces",
+		"infra/gone.tf":        "deleted",
+	})
+	defer cleanup()
+	writeFixture(t, dir, map[string]string{
+		"infra/net/main.tf":   "modified",
+		"infra/net/draft.tf":  "uncommitted",
+		"infra/net/README.md": "uncommitted",
+	})
+	if err := os.Remove(filepath.Join(dir, "infra", "gone.tf")); err != nil {
+		t.Fatal(err)
+	}
+	defer chdir(t, dir)()
+
+	src, err := newGitFiles("infra", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	walked := []string{}
+	err = src.walk("infra", func(path string) error {
+		walked = append(walked, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join("infra", "gone.tf"), filepath.Join("infra", "my app", "main.tf"), filepath.Join("infra", "net", "main.tf")}
+	if !equalStrings(walked, want) {
+		t.Errorf("walked %q, want %q", walked, want)
+	}
+
+	tests := []struct {
+		path    string
+		content string
+		exists  bool
+	}{
+		{"infra/net/main.tf", "committed", true},
+		{"./infra/net/../net/main.tf", "committed", true},
+		{"infra/my app/main.tf", "spaces", true},
+		{"infra/gone.tf", "deleted", true},
+		{"infra/net/draft.tf", "", false},
+		{"infra/net", "", false},
+		{"README.md", "", false},
+	}
+	for _, tt := range tests {
+		path := filepath.FromSlash(tt.path)
+		if exists := src.exists(path); exists != tt.exists {
+			t.Errorf("'%s' exists is %t, want %t", tt.path, exists, tt.exists)
+		}
+		content, err := src.readFile(path)
+		if !tt.exists {
+			if !os.IsNotExist(err) {
+				t.Errorf("reading '%s' returned %v, want an error saying it does not exist", tt.path, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("could not read '%s': %s", tt.path, err.Error())
+		} else if string(content) != tt.content {
+			t.Errorf("'%s' contains %q, want %q", tt.path, content, tt.content)
+		}
+	}
+
+	if _, err := newGitFiles("infra", "unknown"); err == nil {
+		t.Errorf("expected an error for an unknown revision")
+	}
+}
+
+func TestGitFilesAtOlderRevision(t *testing.T) {
+	dir, cleanup := gitFixture(t, map[string]string{"net/main.tf": "first"})
+	defer cleanup()
+	writeFixture(t, dir, map[string]string{"net/main.tf": "second", "api/main.tf": "added"})
+	runGit(t, dir, "add", "-A")
+	runGit(t, dir, "commit", "-q", "-m", "second")
+	defer chdir(t, dir)()
+
+	src, err := newGitFiles(".", "HEAD~1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := src.readFile(filepath.Join("net", "main.tf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "first" {
+		t.Errorf("net/main.tf contains %q at HEAD~1, want %q", content, "first")
+	}
+	if src.exists(filepath.Join("api", "main.tf")) {
+		t.Errorf("api/main.tf exists at HEAD~1")
+	}
+}
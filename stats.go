@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PlanStats describes the shape of an execution plan.
+type PlanStats struct {
+	TierWidths           []int             `json:"tier_widths"`
+	CriticalPath         []string          `json:"critical_path"`
+	CriticalPathDuration float64           `json:"critical_path_seconds,omitempty"`
+	Workspaces           []*WorkspaceStats `json:"workspaces"`
+	Concurrency          int               `json:"concurrency,omitempty"`
+	EstimatedDuration    float64           `json:"estimated_seconds,omitempty"`
+	MissingDurations     []string          `json:"missing_durations,omitempty"`
+}
+
+// WorkspaceStats describes a single workspace within an execution plan. FanIn
+// and FanOut only count producers and consumers that are part of the plan.
+type WorkspaceStats struct {
+	Root     string  `json:"root"`
+	Tier     int     `json:"tier"`
+	FanIn    int     `json:"fan_in"`
+	FanOut   int     `json:"fan_out"`
+	Duration float64 `json:"duration_seconds,omitempty"`
+}
+
+// Durations holds the expected duration in seconds of each workspace indexed
+// by its root.
+type Durations map[string]float64
+
+// ReadDurations reads a JSON object mapping workspace roots to seconds.
+func ReadDurations(path string) (Durations, error) {
+	d := Durations{}
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return d, err
+	}
+	err = json.Unmarshal(raw, &d)
+	if err != nil {
+		return d, fmt.Errorf("could not read durations from '%s': %s", path, err.Error())
+	}
+	return d.normalize(), nil
+}
+
+// ReadRunLogDurations reads a run log written by 'solaris run' and returns the
+// average duration of all successful commands per workspace.
+func ReadRunLogDurations(path string) (Durations, error) {
+	d := Durations{}
+	f, err := os.Open(path)
+	if err != nil {
+		return d, err
+	}
+	defer f.Close()
+
+	count := map[string]int{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		entry := RunLogEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return d, fmt.Errorf("could not read run log '%s': %s", path, err.Error())
+		}
+		if entry.Step != stepCommand || entry.Status != statusDone {
+			continue
+		}
+		d[entry.Workspace] += entry.Duration
+		count[entry.Workspace]++
+	}
+	for root := range d {
+		d[root] = d[root] / float64(count[root])
+	}
+	return d.normalize(), scanner.Err()
+}
+
+func (d Durations) normalize() Durations {
+	out := Durations{}
+	for root, seconds := range d {
+		out[strings.TrimSuffix(root, "/")] = seconds
+	}
+	return out
+}
+
+func (d Durations) get(ws *Workspace) (float64, bool) {
+	seconds, ok := d[strings.TrimSuffix(ws.Root, "/")]
+	return seconds, ok
+}
+
+// GetPlanStats computes tier widths, fan-in and fan-out of the workspaces
+// and the critical path of the plan given. If durations are passed the
+// critical path is weighted by them and the wall-clock time to execute the
+// plan with the concurrency given is estimated. Workspaces without known
+// duration are assumed to take the average of the known ones. If the duration
+// of no workspace in the plan is known, the durations are ignored.
+func GetPlanStats(plan [][]*Workspace, durations Durations, concurrency int) PlanStats {
+	stats := PlanStats{
+		TierWidths:   []int{},
+		CriticalPath: []string{},
+		Workspaces:   []*WorkspaceStats{},
+	}
+
+	inPlan := map[*Workspace]*WorkspaceStats{}
+	order := []*Workspace{}
+	for tier, workspaces := range plan {
+		stats.TierWidths = append(stats.TierWidths, len(workspaces))
+		sorted := make([]*Workspace, len(workspaces))
+		copy(sorted, workspaces)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i].Root < sorted[j].Root })
+		for _, ws := range sorted {
+			s := &WorkspaceStats{Root: ws.Root, Tier: tier + 1}
+			inPlan[ws] = s
+			order = append(order, ws)
+			stats.Workspaces = append(stats.Workspaces, s)
+		}
+	}
+
+	deps := map[*Workspace][]*Workspace{}
+	for _, ws := range order {
+		for _, p := range producers(ws) {
+			if _, ok := inPlan[p]; ok {
+				deps[ws] = append(deps[ws], p)
+				inPlan[ws].FanIn++
+				inPlan[p].FanOut++
+			}
+		}
+	}
+
+	// weigh workspaces by their duration if any are known, otherwise every
+	// workspace counts as one and the wall-clock time is not estimated
+	known, total := 0, 0.0
+	for _, ws := range order {
+		if seconds, ok := durations.get(ws); ok {
+			known++
+			total += seconds
+		}
+	}
+	weight := map[*Workspace]float64{}
+	for _, ws := range order {
+		seconds, ok := durations.get(ws)
+		if !ok && len(durations) > 0 {
+			stats.MissingDurations = append(stats.MissingDurations, ws.Root)
+		}
+		switch {
+		case known == 0:
+			weight[ws] = 1
+			continue
+		case !ok:
+			seconds = total / float64(known)
+		}
+		weight[ws] = seconds
+		inPlan[ws].Duration = seconds
+	}
+
+	// longest path, tiers are in topological order
+	dist := map[*Workspace]float64{}
+	prev := map[*Workspace]*Workspace{}
+	var last *Workspace
+	for _, ws := range order {
+		dist[ws] = weight[ws]
+		for _, p := range deps[ws] {
+			if dist[p]+weight[ws] > dist[ws] {
+				dist[ws] = dist[p] + weight[ws]
+				prev[ws] = p
+			}
+		}
+		if last == nil || dist[ws] > dist[last] {
+			last = ws
+		}
+	}
+	for ws := last; ws != nil; ws = prev[ws] {
+		stats.CriticalPath = append([]string{ws.Root}, stats.CriticalPath...)
+	}
+
+	if known > 0 {
+		stats.CriticalPathDuration = dist[last]
+		if concurrency < 1 {
+			concurrency = 1
+		}
+		stats.Concurrency = concurrency
+		stats.EstimatedDuration = simulateExecution(order, deps, weight, concurrency)
+	}
+
+	return stats
+}
+
+// simulateExecution returns the time needed to execute all workspaces given
+// with a limited number of workers, starting ready workspaces in plan order.
+func simulateExecution(order []*Workspace, deps map[*Workspace][]*Workspace, weight map[*Workspace]float64, concurrency int) float64 {
+	finished := map[*Workspace]float64{}
+	running := map[*Workspace]float64{}
+	now := 0.0
+
+	for len(finished) < len(order) {
+		for _, ws := range order {
+			if len(running) >= concurrency {
+				break
+			}
+			if _, ok := finished[ws]; ok {
+				continue
+			}
+			if _, ok := running[ws]; ok {
+				continue
+			}
+			ready := true
+			for _, d := range deps[ws] {
+				if _, ok := finished[d]; !ok {
+					ready = false
+				}
+			}
+			if ready {
+				running[ws] = now + weight[ws]
+			}
+		}
+
+		// bail out if nothing can be started, e.g. due to circular dependencies
+		if len(running) == 0 {
+			break
+		}
+
+		// advance to the next workspace finishing
+		next := -1.0
+		for _, end := range running {
+			if next < 0 || end < next {
+				next = end
+			}
+		}
+		now = next
+		for ws, end := range running {
+			if end <= now {
+				finished[ws] = end
+				delete(running, ws)
+			}
+		}
+	}
+	return now
+}
+
+// PrintPlanStats writes a human readable report of the stats given.
+func PrintPlanStats(w io.Writer, stats PlanStats) {
+	fmt.Fprintf(w, "Tiers:\n")
+	for i, width := range stats.TierWidths {
+		fmt.Fprintf(w, "    %d: %d workspace(s)\n", i+1, width)
+	}
+
+	fmt.Fprintf(w, "\nCritical path:\n")
+	fmt.Fprintf(w, "    %s\n", strings.Join(stats.CriticalPath, " -> "))
+	if stats.CriticalPathDuration > 0 {
+		fmt.Fprintf(w, "    takes %s\n", formatSeconds(stats.CriticalPathDuration))
+	}
+
+	fmt.Fprintf(w, "\nWorkspaces (by fan-out):\n")
+	workspaces := make([]*WorkspaceStats, len(stats.Workspaces))
+	copy(workspaces, stats.Workspaces)
+	sort.SliceStable(workspaces, func(i, j int) bool {
+		if workspaces[i].FanOut != workspaces[j].FanOut {
+			return workspaces[i].FanOut > workspaces[j].FanOut
+		}
+		return workspaces[i].Root < workspaces[j].Root
+	})
+	for _, ws := range workspaces {
+		fmt.Fprintf(w, "    %-40s tier %-3d fan-in %-3d fan-out %d", ws.Root, ws.Tier, ws.FanIn, ws.FanOut)
+		if ws.Duration > 0 {
+			fmt.Fprintf(w, " %s", formatSeconds(ws.Duration))
+		}
+		fmt.Fprintf(w, "\n")
+	}
+
+	switch {
+	case stats.Concurrency > 0:
+		fmt.Fprintf(w, "\nEstimated wall-clock time with concurrency %d: %s\n", stats.Concurrency, formatSeconds(stats.EstimatedDuration))
+		if len(stats.MissingDurations) > 0 {
+			fmt.Fprintf(w, "    no duration known for %s, assumed average\n", strings.Join(stats.MissingDurations, ", "))
+		}
+	case len(stats.MissingDurations) > 0:
+		fmt.Fprintf(w, "\nNo duration known for any workspace of the plan, wall-clock time not estimated\n")
+	}
+}
+
+func formatSeconds(seconds float64) string {
+	return (time.Duration(seconds * float64(time.Second))).Round(time.Second).String()
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestGetPlanStats(t *testing.T) {
+	files := map[string]string{
+		"net/main.tf": tfBackend("net") + tfOutput("vpc_id", `"vpc"`),
+		"api/main.tf": tfBackend("api") + tfRemoteState("net", "net") + tfOutput("url", "data.terraform_remote_state.net.outputs.vpc_id"),
+		"web/main.tf": tfBackend("web") + tfRemoteState("api", "api") + tfOutput("host", "data.terraform_remote_state.api.outputs.url"),
+		"dns/main.tf": tfBackend("dns") + tfRemoteState("net", "net") + tfOutput("record", "data.terraform_remote_state.net.outputs.vpc_id"),
+	}
+	dir, cleanup := tempFixture(t, files)
+	defer cleanup()
+	defer chdir(t, dir)()
+
+	workspaces, err := GetWorkspaces(".", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := BuildExecutionPlan(workspaceSlice(workspaces), []string{}, func(string) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		durations    Durations
+		concurrency  int
+		criticalPath []string
+		pathSeconds  float64
+		estimated    float64
+		missing      []string
+	}{
+		{"no durations", nil, 1, []string{"net/", "api/", "web/"}, 0, 0, nil},
+		{"all durations", Durations{"net": 10, "api": 20, "web": 30, "dns": 100}, 1, []string{"net/", "dns/"}, 110, 160, nil},
+		{"all durations concurrently", Durations{"net": 10, "api": 20, "web": 30, "dns": 100}, 2, []string{"net/", "dns/"}, 110, 110, nil},
+		{"unlimited concurrency", Durations{"net": 10, "api": 20, "web": 30, "dns": 100}, 0, []string{"net/", "dns/"}, 110, 160, nil},
+		{"partial durations", Durations{"net": 10, "api": 20, "web": 30}, 2, []string{"net/", "api/", "web/"}, 60, 60, []string{"dns/"}},
+		{"unmatched durations", Durations{"other": 100}, 2, []string{"net/", "api/", "web/"}, 0, 0, []string{"net/", "api/", "dns/", "web/"}},
+	}
+	for _, tt := range tests {
+		stats := GetPlanStats(plan, tt.durations, tt.concurrency)
+		if !equalInts(stats.TierWidths, []int{1, 2, 1}) {
+			t.Errorf("%s: tier widths are %v, want [1 2 1]", tt.name, stats.TierWidths)
+		}
+		if !equalStrings(stats.CriticalPath, tt.criticalPath) {
+			t.Errorf("%s: critical path is %q, want %q", tt.name, stats.CriticalPath, tt.criticalPath)
+		}
+		if stats.CriticalPathDuration != tt.pathSeconds {
+			t.Errorf("%s: critical path takes %.0fs, want %.0fs", tt.name, stats.CriticalPathDuration, tt.pathSeconds)
+		}
+		if stats.EstimatedDuration != tt.estimated {
+			t.Errorf("%s: estimated %.0fs, want %.0fs", tt.name, stats.EstimatedDuration, tt.estimated)
+		}
+		if !equalStrings(stats.MissingDurations, tt.missing) {
+			t.Errorf("%s: missing durations are %q, want %q", tt.name, stats.MissingDurations, tt.missing)
+		}
+	}
+}
+
+func TestGetPlanStatsFanInFanOut(t *testing.T) {
+	files := map[string]string{
+		"net/main.tf": tfBackend("net") + tfOutput("vpc_id", `"vpc"`),
+		"api/main.tf": tfBackend("api") + tfRemoteState("net", "net") + tfOutput("url", "data.terraform_remote_state.net.outputs.vpc_id"),
+		"dns/main.tf": tfBackend("dns") + tfRemoteState("net", "net") + tfOutput("record", "data.terraform_remote_state.net.outputs.vpc_id"),
+	}
+	dir, cleanup := tempFixture(t, files)
+	defer cleanup()
+	defer chdir(t, dir)()
+
+	workspaces, err := GetWorkspaces(".", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		roots []string
+		fan   map[string][2]int
+	}{
+		{[]string{}, map[string][2]int{"net/": {0, 2}, "api/": {1, 0}, "dns/": {1, 0}}},
+		// producers outside of the plan are not counted
+		{[]string{"api/"}, map[string][2]int{"api/": {0, 0}}},
+	}
+	for _, tt := range tests {
+		plan, err := BuildExecutionPlan(workspaceSlice(workspaces), tt.roots, func(string) {})
+		if err != nil {
+			t.Fatal(err)
+		}
+		stats := GetPlanStats(plan, nil, 1)
+		if len(stats.Workspaces) != len(tt.fan) {
+			t.Errorf("roots %q: got stats of %d workspaces, want %d", tt.roots, len(stats.Workspaces), len(tt.fan))
+		}
+		for _, s := range stats.Workspaces {
+			if fan := tt.fan[s.Root]; s.FanIn != fan[0] || s.FanOut != fan[1] {
+				t.Errorf("roots %q: %s has fan-in %d and fan-out %d, want %d and %d", tt.roots, s.Root, s.FanIn, s.FanOut, fan[0], fan[1])
+			}
+		}
+	}
+}
+
+func TestPrintPlanStats(t *testing.T) {
+	tests := []struct {
+		name  string
+		stats PlanStats
+		want  string
+	}{
+		{"estimated", PlanStats{Concurrency: 2, EstimatedDuration: 90, MissingDurations: []string{"dns/"}}, "no duration known for dns/, assumed average"},
+		{"not estimated", PlanStats{MissingDurations: []string{"net/", "dns/"}}, "wall-clock time not estimated"},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		PrintPlanStats(&out, tt.stats)
+		if !strings.Contains(out.String(), tt.want) {
+			t.Errorf("%s: report does not contain '%s':\n%s", tt.name, tt.want, out.String())
+		}
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+const (
+	svgClusterHeader = 28.0
+	svgClusterPad    = 10.0
+	svgGroupHeader   = 18.0
+	svgPortH         = 22.0
+	svgPortGap       = 6.0
+	svgDefaultFill   = "#eef4fb"
+	svgDefaultStroke = "#4a90d9"
+	svgWarningStroke = "#e69500"
+)
+
+type svgExporter struct{}
+
+// Export lays out and draws the graph as SVG document without relying on
+// graphviz. Workspaces are placed in layers from producers on the left to
+// consumers on the right, workspaces of detailed graphs are drawn as clusters
+// containing a cluster of their inputs on the left and one of their outputs on
+// the right.
+func (svgExporter) Export(w io.Writer, g *Graph) error {
+	_, err := fmt.Fprintln(w, graphSVG(g, ""))
+	return err
+}
+
+// svgPort is the position of an output or input within its cluster.
+type svgPort struct {
+	node *GraphNode
+	x, y float64
+	w    float64
+}
+
+// graphSVG draws the graph given. Workspaces are drawn as groups of class
+// 'node' and edges as paths of class 'edge', both refer to workspace ids in
+// their data attributes.
+func graphSVG(g *Graph, id string) string {
+	roots := g.roots()
+	labels := map[string]string{}
+	ids := []string{}
+	for _, n := range roots {
+		ids = append(ids, n.ID)
+		labels[n.ID] = n.Label
+	}
+
+	// split children of detailed graphs into inputs and outputs
+	inputs, outputs := map[string][]*GraphNode{}, map[string][]*GraphNode{}
+	inputW, outputW := map[string]float64{}, map[string]float64{}
+	for _, n := range roots {
+		for _, child := range g.children(n.ID) {
+			w, _ := labelSize(child.Label)
+			if child.Kind == nodeKindInput {
+				inputs[n.ID] = append(inputs[n.ID], child)
+				if w > inputW[n.ID] {
+					inputW[n.ID] = w
+				}
+			} else {
+				outputs[n.ID] = append(outputs[n.ID], child)
+				if w > outputW[n.ID] {
+					outputW[n.ID] = w
+				}
+			}
+		}
+	}
+
+	size := func(id string) (float64, float64) {
+		w, h := labelSize(labels[id])
+		if !g.Detailed {
+			return w, h
+		}
+		rows := len(inputs[id])
+		if len(outputs[id]) > rows {
+			rows = len(outputs[id])
+		}
+		ports := inputW[id] + outputW[id] + 3*svgClusterPad + 4*svgPortGap
+		if ports > w {
+			w = ports
+		}
+		if rows == 0 {
+			return w, svgClusterHeader + svgClusterPad
+		}
+		return w, svgClusterHeader + svgGroupHeader + float64(rows)*(svgPortH+svgPortGap) + svgClusterPad
+	}
+	boxes, width, height := layeredLayout(ids, g.workspaceEdges(), size)
+
+	// position ports within the clusters of inputs and outputs
+	ports := map[string]*svgPort{}
+	for _, n := range roots {
+		b := boxes[n.ID]
+		top := b.Y + svgClusterHeader + svgGroupHeader
+		for i, in := range inputs[n.ID] {
+			ports[in.ID] = &svgPort{node: in, x: b.X + svgClusterPad + svgPortGap, y: top + float64(i)*(svgPortH+svgPortGap), w: inputW[n.ID]}
+		}
+		for i, out := range outputs[n.ID] {
+			ports[out.ID] = &svgPort{node: out, x: b.X + b.W - svgClusterPad - svgPortGap - outputW[n.ID], y: top + float64(i)*(svgPortH+svgPortGap), w: outputW[n.ID]}
+		}
+	}
+
+	workspace := map[string]string{}
+	for _, n := range g.Nodes {
+		workspace[n.ID] = n.Workspace
+	}
+
+	var b strings.Builder
+	idAttr := ""
+	if id != "" {
+		idAttr = fmt.Sprintf(` id="%s"`, html.EscapeString(id))
+	}
+	fmt.Fprintf(&b, `<svg%s xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f" viewBox="0 0 %.0f %.0f" font-family="sans-serif" font-size="12">`, idAttr, width, height, width, height)
+	fmt.Fprintf(&b, `<defs><marker id="arrow" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="8" markerHeight="8" orient="auto"><path d="M0,0 L10,5 L0,10 z" fill="#888"/></marker></defs>`)
+
+	for _, n := range roots {
+		box := boxes[n.ID]
+		fill, stroke := svgNodeColors(n)
+		fmt.Fprintf(&b, `<g class="node" data-id="%s">`, html.EscapeString(n.ID))
+		if g.Detailed {
+			fmt.Fprintf(&b, `<rect x="%.0f" y="%.0f" width="%.0f" height="%.0f" rx="6" fill="%s" stroke="%s"/>`, box.X, box.Y, box.W, box.H, html.EscapeString(fill), html.EscapeString(stroke))
+			fmt.Fprintf(&b, `<text x="%.0f" y="%.0f" text-anchor="middle" dominant-baseline="middle" font-weight="bold">%s</text>`, box.X+box.W/2, box.Y+svgClusterHeader/2, html.EscapeString(n.Label))
+		} else {
+			fmt.Fprintf(&b, `<%s fill="%s" stroke="%s"/>`, svgShape(n.Shape, box.X, box.Y, box.W, box.H), html.EscapeString(fill), html.EscapeString(stroke))
+			fmt.Fprintf(&b, `<text x="%.0f" y="%.0f" text-anchor="middle" dominant-baseline="middle">%s</text>`, box.X+box.W/2, box.Y+box.H/2, html.EscapeString(n.Label))
+		}
+		fmt.Fprintf(&b, `<title>%s</title>`, html.EscapeString(svgTitle(n)))
+		for _, group := range []struct {
+			kind  string
+			ports []*GraphNode
+		}{{nodeKindInput, inputs[n.ID]}, {nodeKindOutput, outputs[n.ID]}} {
+			if len(group.ports) == 0 {
+				continue
+			}
+			first := ports[group.ports[0].ID]
+			x, y := first.x-svgPortGap, first.y-svgGroupHeader
+			h := svgGroupHeader + float64(len(group.ports))*(svgPortH+svgPortGap)
+			fmt.Fprintf(&b, `<g class="cluster" data-kind="%ss"><rect x="%.0f" y="%.0f" width="%.0f" height="%.0f" rx="4" fill="none" stroke="#bbb" stroke-dasharray="4 2"/>`, group.kind, x, y, first.w+2*svgPortGap, h)
+			fmt.Fprintf(&b, `<text x="%.0f" y="%.0f" text-anchor="middle" dominant-baseline="middle" font-size="10" fill="#555">%ss</text></g>`, x+first.w/2+svgPortGap, y+svgGroupHeader/2, group.kind)
+		}
+		for _, child := range append(append([]*GraphNode{}, inputs[n.ID]...), outputs[n.ID]...) {
+			p := ports[child.ID]
+			childStroke := "#888"
+			if child.Color != "" {
+				childStroke = child.Color
+			}
+			fmt.Fprintf(&b, `<g class="port"><rect x="%.0f" y="%.0f" width="%.0f" height="%.0f" rx="3" fill="#fff" stroke="%s"/>`, p.x, p.y, p.w, svgPortH, html.EscapeString(childStroke))
+			fmt.Fprintf(&b, `<text x="%.0f" y="%.0f" text-anchor="middle" dominant-baseline="middle">%s</text><title>%s</title></g>`, p.x+p.w/2, p.y+svgPortH/2, html.EscapeString(child.Label), html.EscapeString(svgTitle(child)))
+		}
+		b.WriteString(`</g>`)
+	}
+
+	// edges point from consumers to producers, arrows show the flow of data
+	for _, e := range g.Edges {
+		var x1, y1, x2, y2 float64
+		if g.Detailed {
+			producer, consumer := ports[e.To], ports[e.From]
+			if producer == nil || consumer == nil {
+				continue
+			}
+			x1, y1 = producer.x+producer.w, producer.y+svgPortH/2
+			x2, y2 = consumer.x, consumer.y+svgPortH/2
+		} else {
+			producer, consumer := boxes[e.To], boxes[e.From]
+			if producer == nil || consumer == nil {
+				continue
+			}
+			x1, y1 = producer.X+producer.W, producer.Y+producer.H/2
+			x2, y2 = consumer.X, consumer.Y+consumer.H/2
+		}
+		color := "#888"
+		if e.Color != "" {
+			color = e.Color
+		}
+		title := labels[workspace[e.To]] + " -> " + labels[workspace[e.From]]
+		if e.Data["outputs"] != "" {
+			title += "\n" + e.Data["outputs"]
+		} else if e.Label != "" {
+			title += "\n" + e.Label
+		}
+		fmt.Fprintf(&b, `<path class="edge" data-from="%s" data-to="%s" d="M%.0f,%.0f C%.0f,%.0f %.0f,%.0f %.0f,%.0f" fill="none" stroke="%s" stroke-width="1.5" marker-end="url(#arrow)"><title>%s</title></path>`,
+			html.EscapeString(workspace[e.From]), html.EscapeString(workspace[e.To]), x1, y1, (x1+x2)/2, y1, (x1+x2)/2, y2, x2, y2, html.EscapeString(color), html.EscapeString(title))
+		if !g.Detailed && e.Label != "" {
+			fmt.Fprintf(&b, `<text class="edge-label" x="%.0f" y="%.0f" text-anchor="middle" font-size="10" fill="#555">%s</text>`, (x1+x2)/2, (y1+y2)/2-4, html.EscapeString(e.Label))
+		}
+	}
+
+	b.WriteString(`</svg>`)
+	return b.String()
+}
+
+// svgNodeColors returns fill and stroke of a workspace, workspaces with lint
+// findings not considered errors are outlined orange.
+func svgNodeColors(n *GraphNode) (string, string) {
+	fill, stroke := svgDefaultFill, svgDefaultStroke
+	if n.Fill != "" {
+		fill = n.Fill
+	}
+	if n.Data["lint"] != "" {
+		stroke = svgWarningStroke
+	}
+	if n.Color != "" {
+		stroke = n.Color
+	}
+	return fill, stroke
+}
+
+// svgShape returns the element drawing a node with the shape given, nodes
+// with manuals get a pointed edge on the side of their manual.
+func svgShape(shape string, x, y, w, h float64) string {
+	const d = 10.0
+	var points [][2]float64
+	switch shape {
+	case nodeShapePreManual:
+		points = [][2]float64{{x + d, y}, {x + w, y}, {x + w, y + h}, {x + d, y + h}, {x, y + h/2}}
+	case nodeShapePostManual:
+		points = [][2]float64{{x, y}, {x + w - d, y}, {x + w, y + h/2}, {x + w - d, y + h}, {x, y + h}}
+	case nodeShapeManual:
+		points = [][2]float64{{x + d, y}, {x + w - d, y}, {x + w, y + h/2}, {x + w - d, y + h}, {x + d, y + h}, {x, y + h/2}}
+	default:
+		return fmt.Sprintf(`rect x="%.0f" y="%.0f" width="%.0f" height="%.0f" rx="4"`, x, y, w, h)
+	}
+	coords := []string{}
+	for _, p := range points {
+		coords = append(coords, fmt.Sprintf("%.0f,%.0f", p[0], p[1]))
+	}
+	return fmt.Sprintf(`polygon points="%s"`, strings.Join(coords, " "))
+}
+
+func svgTitle(n *GraphNode) string {
+	if summary := dataSummary(n.Data); summary != "" {
+		return n.Label + "\n" + summary
+	}
+	return n.Label
+}
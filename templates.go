@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const defaultTemplateName = "markdown"
+
+// builtinTemplates are the templates shipped with solaris to render an
+// execution plan, selectable by name.
+var builtinTemplates = map[string]string{
+	"markdown":  markdownTemplate,
+	"html":      htmlTemplate,
+	"checklist": checklistTemplate,
+}
+
+// GetBuiltinTemplate returns the source of the built-in template with the
+// name given.
+func GetBuiltinTemplate(name string) (string, error) {
+	tmpl, ok := builtinTemplates[name]
+	if !ok {
+		return "", fmt.Errorf("template '%s' does not exist, use one of '%s'", name, strings.Join(BuiltinTemplateNames(), "', '"))
+	}
+	return tmpl, nil
+}
+
+// BuiltinTemplateNames returns the names of all built-in templates, sorted.
+func BuiltinTemplateNames() []string {
+	names := []string{}
+	for name := range builtinTemplates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+const markdownTemplate = `# Runbook
+
+Generated by solaris {{ .Version }}{{ if not .GeneratedAt.IsZero }} on {{ .GeneratedAt.Format "2006-01-02 15:04" }}{{ end }}.
+{{ range $i, $tier := .Tiers }}
+## Tier {{ add $i 1 }}
+{{ range $tier }}
+<a id="{{ index $.Anchors .Root }}"></a>
+### {{ .Root }}
+{{ with dependsOn . }}
+Depends on {{ range $j, $ws := . }}{{ if $j }}, {{ end }}{{ with index $.Anchors $ws.Root }}[{{ $ws.Root }}](#{{ . }}){{ else }}{{ $ws.Root }}{{ end }}{{ end }}.
+{{ end }}{{ if .PreManual }}
+#### Before applying
+
+{{ trim .PreManualMarkdown }}
+{{ end }}
+Apply the terraform configuration:
+
+` + "```" + `
+cd {{ .Root }}
+terraform init
+terraform apply
+` + "```" + `
+{{ if .PostManual }}
+#### After applying
+
+{{ trim .PostManualMarkdown }}
+{{ end }}{{ end }}{{ end }}`
+
+const htmlTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Runbook</title>
+<style>
+body { font-family: sans-serif; max-width: 60em; margin: 2em auto; color: #222; }
+section.tier { border-left: 4px solid #4a90d9; padding-left: 1em; margin-bottom: 2em; }
+div.manual { background: #fdf6e3; padding: 0.5em 1em; border-radius: 4px; }
+pre { background: #f4f4f4; padding: 0.5em; }
+</style>
+</head>
+<body>
+<h1>Runbook</h1>
+<p>Generated by solaris {{ html .Version }}{{ if not .GeneratedAt.IsZero }} on {{ .GeneratedAt.Format "2006-01-02 15:04" }}{{ end }}.</p>
+{{ range $i, $tier := .Tiers }}<section class="tier">
+<h2>Tier {{ add $i 1 }}</h2>
+{{ range $tier }}<article>
+<h3 id="{{ index $.Anchors .Root }}">{{ html .Root }}</h3>
+{{ with dependsOn . }}<p>Depends on {{ range $j, $ws := . }}{{ if $j }}, {{ end }}{{ with index $.Anchors $ws.Root }}<a href="#{{ . }}">{{ html $ws.Root }}</a>{{ else }}{{ html $ws.Root }}{{ end }}{{ end }}.</p>
+{{ end }}{{ if .PreManual }}<h4>Before applying</h4>
+<div class="manual">
+{{ .PreManualRendered }}
+</div>
+{{ end }}<p>Apply the terraform configuration:</p>
+<pre>cd {{ html .Root }}
+terraform init
+terraform apply</pre>
+{{ if .PostManual }}<h4>After applying</h4>
+<div class="manual">
+{{ .PostManualRendered }}
+</div>
+{{ end }}</article>
+{{ end }}</section>
+{{ end }}</body>
+</html>
+`
+
+const checklistTemplate = `Runbook
+=======
+{{ range $i, $tier := .Tiers }}
+Tier {{ add $i 1 }}
+{{ range $tier }}{{ if .PreManual }}
+[ ] {{ .Root }}: manual steps before applying (see {{ .Root }}PreManual.md)
+{{ end }}[ ] {{ .Root }}: terraform apply
+{{ if .PostManual }}[ ] {{ .Root }}: manual steps after applying (see {{ .Root }}PostManual.md)
+{{ end }}{{ end }}{{ end }}`
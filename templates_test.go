@@ -0,0 +1,148 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuiltinTemplateManuals(t *testing.T) {
+	tests := []struct {
+		template string
+		want     []string
+		unwanted []string
+	}{
+		{"markdown", []string{"Create the **record**.", "Check the *zone*."}, []string{"<p>", "<strong>"}},
+		{"html", []string{"<p>Create the <strong>record</strong>.</p>", "<p>Check the <em>zone</em>.</p>"}, []string{"**record**"}},
+	}
+	for _, tt := range tests {
+		plan := [][]*Workspace{{{Root: "dns/", PreManual: "Create the **record**.\n", PostManual: "Check the *zone*.\n"}}}
+		if err := RenderManuals(plan, false); err != nil {
+			t.Fatal(err)
+		}
+		tmpl, err := GetBuiltinTemplate(tt.template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := RenderExecutionPlan(NewTemplateContext(plan, ".", time.Time{}), tmpl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(out, want) {
+				t.Errorf("%s template does not contain '%s':\n%s", tt.template, want, out)
+			}
+		}
+		for _, unwanted := range tt.unwanted {
+			if strings.Contains(out, unwanted) {
+				t.Errorf("%s template contains '%s':\n%s", tt.template, unwanted, out)
+			}
+		}
+	}
+}
+
+func TestRenderExecutionPlanContext(t *testing.T) {
+	plan := [][]*Workspace{{{Root: "net/"}}, {{Root: "api/"}, {Root: "web/"}}}
+	ctx := NewTemplateContext(plan, ".", time.Time{})
+
+	tests := []struct {
+		template string
+		want     string
+		err      bool
+	}{
+		{`{{ range .Tiers }}{{ range . }}{{ .Root }} {{ end }}{{ end }}`, "net/ api/ web/ ", false},
+		{`{{ range .Workspaces }}{{ title .Root }} {{ end }}`, "Net/ Api/ Web/ ", false},
+		{`{{ title "terraform apply, re-run" }}`, "Terraform Apply, Re-Run", false},
+		{`{{ title "my_workspace 2nd" }}`, "My_workspace 2nd", false},
+		{`{{ range . }}{{ range . }}{{ .Root }}{{ end }}{{ end }}`, "", true},
+		{`{{ if .Tiers }}{{ range . }}{{ end }}{{ end }}`, "", true},
+		{`{{ .GeneratedAt.IsZero }}`, "true", false},
+		{`{{ with .Tiers }}{{ range . }}{{ len . }}{{ end }}{{ end }}`, "12", false},
+		{`{{ with .Version }}{{ else }}{{ range . }}{{ end }}{{ end }}`, "", true},
+		{`{{ with . }}{{ range . }}{{ end }}{{ end }}`, "", true},
+		{`{{ range .Tiers }}{{ range $ }}{{ end }}{{ end }}`, "", true},
+		{`{{ range .Tiers }}{{ with $ }}{{ range .Tiers }}{{ end }}{{ end }}{{ end }}`, "", false},
+		{`{{ define "tier" }}{{ range . }}{{ .Root }} {{ end }}{{ end }}{{ range .Tiers }}{{ template "tier" . }}{{ end }}`, "net/ api/ web/ ", false},
+		{`{{ define "tiers" }}{{ range . }}{{ end }}{{ end }}{{ template "tiers" . }}`, "", true},
+		{`{{ define "tiers" }}{{ range . }}{{ end }}{{ end }}{{ range .Tiers }}{{ template "tiers" $ }}{{ end }}`, "", true},
+		{`{{ block "tiers" . }}{{ range . }}{{ end }}{{ end }}`, "", true},
+		{`{{ define "tiers" }}{{ with $ }}{{ range . }}{{ end }}{{ end }}{{ end }}{{ template "tiers" .Tiers }}`, "", false},
+	}
+	for _, tt := range tests {
+		out, err := RenderExecutionPlan(ctx, tt.template)
+		if tt.err {
+			if err == nil {
+				t.Errorf("expected an error rendering %s", tt.template)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("rendering %s failed: %s", tt.template, err)
+		}
+		if out != tt.want {
+			t.Errorf("%s rendered '%s', want '%s'", tt.template, out, tt.want)
+		}
+	}
+}
+
+func TestBuiltinTemplateAnchors(t *testing.T) {
+	dir, cleanup := tempFixture(t, map[string]string{
+		"net/main.tf":      tfBackend("net") + tfOutput("vpc_id", `"vpc"`),
+		"apps/api/main.tf": tfBackend("apps/api") + tfOutput("url", `"https://api"`),
+		"apps-api/main.tf": tfBackend("apps-api") + tfOutput("url", `"https://legacy"`),
+		"web/main.tf": tfBackend("web") + tfRemoteState("api", "apps/api") + tfRemoteState("legacy", "apps-api") + tfRemoteState("net", "net") +
+			tfOutput("urls", "[data.terraform_remote_state.api.outputs.url, data.terraform_remote_state.legacy.outputs.url, data.terraform_remote_state.net.outputs.vpc_id]"),
+	})
+	defer cleanup()
+	defer chdir(t, dir)()
+
+	workspaces, err := GetWorkspaces(".", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// net is applied already and left out of the plan
+	plan := [][]*Workspace{{workspaces["apps/api/"], workspaces["apps-api/"]}, {workspaces["web/"]}}
+
+	tests := []struct {
+		template string
+		ids      *regexp.Regexp
+		links    *regexp.Regexp
+		plain    string
+	}{
+		{"markdown", regexp.MustCompile(`<a id="([^"]*)">`), regexp.MustCompile(`\]\(#([^)]*)\)`), ", net/."},
+		{"html", regexp.MustCompile(`<h3 id="([^"]*)">`), regexp.MustCompile(`href="#([^"]*)"`), ", net/.</p>"},
+	}
+	for _, tt := range tests {
+		tmpl, err := GetBuiltinTemplate(tt.template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := RenderExecutionPlan(NewTemplateContext(plan, ".", time.Time{}), tmpl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := map[string]bool{}
+		for _, m := range tt.ids.FindAllStringSubmatch(out, -1) {
+			if ids[m[1]] {
+				t.Errorf("%s template uses id '%s' more than once", tt.template, m[1])
+			}
+			ids[m[1]] = true
+		}
+		if len(ids) != 3 {
+			t.Errorf("%s template has ids %v, want 3", tt.template, ids)
+		}
+		links := tt.links.FindAllStringSubmatch(out, -1)
+		if len(links) != 2 {
+			t.Errorf("%s template has %d links, want 2", tt.template, len(links))
+		}
+		for _, m := range links {
+			if !ids[m[1]] {
+				t.Errorf("%s template links to unknown id '%s'", tt.template, m[1])
+			}
+		}
+		if !strings.Contains(out, tt.plain) {
+			t.Errorf("%s template does not mention net/ as plain text:\n%s", tt.template, out)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+# generated by solaris, do not edit
+
+.PHONY: all root apps-api apps-api-2 my-app
+
+all: root apps-api apps-api-2 my-app
+
+root:
+	cd '.' && terraform apply -var "home=$$HOME"
+
+apps-api:
+	cd 'apps-api' && terraform apply -var "home=$$HOME"
+
+apps-api-2: root
+	cd 'apps/api' && terraform apply -var "home=$$HOME"
+
+# my app requires manual steps before execution, see my app/PreManual.md
+# my app requires manual steps after execution, see my app/PostManual.md
+my-app: apps-api apps-api-2
+	cd 'my app' && terraform apply -var "home=$$HOME"
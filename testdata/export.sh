@@ -0,0 +1,45 @@
+#!/bin/sh
+# generated by solaris, do not edit
+set -e
+
+confirm() {
+	printf '%s [y/N] ' "$1"
+	read -r answer
+	case "$answer" in
+	y | Y | yes) ;;
+	*)
+		echo 'aborted' >&2
+		exit 1
+		;;
+	esac
+}
+
+# --- tier 1 ---
+
+# .
+echo '=== .'
+(cd '.' && terraform apply -var "home=$HOME")
+
+# apps-api
+echo '=== apps-api'
+(cd 'apps-api' && terraform apply -var "home=$HOME")
+
+# --- tier 2 ---
+
+# apps/api
+# depends on .
+echo '=== apps/api'
+(cd 'apps/api' && terraform apply -var "home=$HOME")
+
+# --- tier 3 ---
+
+# my app
+# depends on apps-api, apps/api
+echo '=== my app: pre manual'
+printf '%s\n' 'Don'\''t apply before `date` is past $DEADLINE'
+confirm 'Is the pre manual of my app done?'
+echo '=== my app'
+(cd 'my app' && terraform apply -var "home=$HOME")
+echo '=== my app: post manual'
+printf '%s\n' 'Announce it'\''s done'
+confirm 'Is the post manual of my app done?'
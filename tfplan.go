@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+)
+
+// TerraformPlan holds the parts of a plan printed by 'terraform show -json'
+// solaris is interested in.
+type TerraformPlan struct {
+	FormatVersion string                   `json:"format_version"`
+	OutputChanges map[string]PlannedChange `json:"output_changes"`
+}
+
+type PlannedChange struct {
+	Actions []string `json:"actions"`
+}
+
+func ReadTerraformPlan(path string) (TerraformPlan, error) {
+	p := TerraformPlan{}
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return p, err
+	}
+	err = json.Unmarshal(raw, &p)
+	if err != nil {
+		return p, fmt.Errorf("could not read terraform plan '%s', make sure it was written by 'terraform show -json': %s", path, err.Error())
+	}
+	return p, nil
+}
+
+// ChangedOutputs returns the names of all outputs with actions other than
+// 'no-op', sorted.
+func (p TerraformPlan) ChangedOutputs() []string {
+	out := []string{}
+	for name, change := range p.OutputChanges {
+		for _, action := range change.Actions {
+			if action != "no-op" {
+				out = append(out, name)
+				break
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ReduceExecutionPlan returns the tiers of the plan given containing only
+// the workspaces whose roots are listed. Empty tiers are dropped.
+func ReduceExecutionPlan(plan [][]*Workspace, roots []string) [][]*Workspace {
+	keep := map[string]bool{}
+	for _, root := range roots {
+		keep[root] = true
+	}
+
+	reduced := [][]*Workspace{}
+	for _, workspaces := range plan {
+		tier := []*Workspace{}
+		for _, ws := range workspaces {
+			if keep[ws.Root] {
+				tier = append(tier, ws)
+			}
+		}
+		if len(tier) > 0 {
+			reduced = append(reduced, tier)
+		}
+	}
+	return reduced
+}
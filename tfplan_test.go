@@ -0,0 +1,93 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestChangedOutputs(t *testing.T) {
+	tests := []struct {
+		plan    string
+		changed []string
+	}{
+		{"tfplan-create.json", []string{"endpoint"}},
+		{"tfplan-update.json", []string{"vpc_id"}},
+		{"tfplan-delete.json", []string{"legacy_ip"}},
+		{"tfplan-noop.json", []string{}},
+		{"tfplan-replace.json", []string{"vpc_id"}},
+	}
+	for _, tt := range tests {
+		p, err := ReadTerraformPlan(filepath.Join("testdata", tt.plan))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := p.ChangedOutputs(); !equalStrings(got, tt.changed) {
+			t.Errorf("%s changes outputs %q, want %q", tt.plan, got, tt.changed)
+		}
+	}
+
+	if _, err := ReadTerraformPlan(filepath.Join("testdata", "tfplan-missing.json")); err == nil {
+		t.Errorf("expected an error reading a plan that does not exist")
+	}
+}
+
+func TestReduceExecutionPlan(t *testing.T) {
+	files := map[string]string{
+		"net/main.tf": tfBackend("net") + tfOutput("vpc_id", `"vpc"`) + tfOutput("region", `"eu-west-1"`) + tfOutput("endpoint", `"lb"`),
+		"api/main.tf": tfBackend("api") + tfRemoteState("net", "net") + tfOutput("url", "data.terraform_remote_state.net.outputs.vpc_id"),
+		"web/main.tf": tfBackend("web") + tfRemoteState("api", "api") + tfOutput("host", "data.terraform_remote_state.api.outputs.url"),
+		"dns/main.tf": tfBackend("dns") + tfRemoteState("net", "net") + tfOutput("record", "data.terraform_remote_state.net.outputs.endpoint"),
+		"mon/main.tf": tfBackend("mon") + tfRemoteState("net", "net") + tfOutput("region", "data.terraform_remote_state.net.outputs.region"),
+	}
+	dir, cleanup := tempFixture(t, files)
+	defer cleanup()
+	plans, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chdir(t, dir)()
+
+	workspaces, err := GetWorkspaces(".", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	net := workspaces["net/"]
+	plan, err := BuildExecutionPlanFrom(workspaceSlice(workspaces), []*Workspace{net}, func(string) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		plan  string
+		order []string
+	}{
+		{"tfplan-create.json", []string{"dns/"}},
+		{"tfplan-update.json", []string{"api/", "web/"}},
+		{"tfplan-delete.json", []string{}},
+		{"tfplan-noop.json", []string{}},
+		{"tfplan-replace.json", []string{"api/", "web/"}},
+	}
+	for _, tt := range tests {
+		p, err := ReadTerraformPlan(filepath.Join(plans, tt.plan))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// outputs removed from the workspace are reported but not analysed
+		outputs := []string{}
+		for _, name := range p.ChangedOutputs() {
+			for _, o := range net.Outputs {
+				if o.Name == name {
+					outputs = append(outputs, name)
+				}
+			}
+		}
+		impacts, err := GetOutputImpact(net, outputs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		order := ReduceExecutionPlan(plan, ImpactedWorkspaces(impacts))
+		if got := planRoots(order); !equalStrings(got, tt.order) {
+			t.Errorf("%s re-applies %q, want %q", tt.plan, got, tt.order)
+		}
+	}
+}
@@ -2,14 +2,11 @@ package main
 
 import (
 	"fmt"
-	"io/ioutil"
-	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
-
-	"github.com/emicklei/dot"
 )
 
 const (
@@ -18,7 +15,22 @@ const (
 	postFileName = "PostManual.md"
 )
 
+// GetWorkspaces discovers all workspaces below root on the local file system.
 func GetWorkspaces(root string, ignore []string) (map[string]*Workspace, error) {
+	return getWorkspaces(localFiles{}, root, ignore)
+}
+
+// GetWorkspacesAt discovers all workspaces below root as of the git revision
+// ref without checking it out.
+func GetWorkspacesAt(root string, ignore []string, ref string) (map[string]*Workspace, error) {
+	src, err := newGitFiles(root, ref)
+	if err != nil {
+		return map[string]*Workspace{}, err
+	}
+	return getWorkspaces(src, root, ignore)
+}
+
+func getWorkspaces(src fileSource, root string, ignore []string) (map[string]*Workspace, error) {
 	workspaces := map[string]*Workspace{}
 
 	matchers := []*regexp.Regexp{}
@@ -31,7 +43,7 @@ func GetWorkspaces(root string, ignore []string) (map[string]*Workspace, error)
 		}
 	}
 
-	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+	err := src.walk(root, func(path string) error {
 		for _, m := range matchers {
 			if m.MatchString(path) {
 				return nil
@@ -58,7 +70,7 @@ func GetWorkspaces(root string, ignore []string) (map[string]*Workspace, error)
 
 	// fetch info per workspace
 	for path, workspace := range workspaces {
-		err = workspace.readFiles(path)
+		err = workspace.readFiles(src, path)
 		if err != nil {
 			return workspaces, err
 		}
@@ -86,17 +98,29 @@ func GetWorkspaces(root string, ignore []string) (map[string]*Workspace, error)
 			return workspaces, err
 		}
 		workspace.Outputs = append(workspace.Outputs, o...)
+
+		m, err := workspace.getModuleSources()
+		if err != nil {
+			return workspaces, err
+		}
+		workspace.Modules = m
+
+		owner, err := workspace.getOwner()
+		if err != nil {
+			return workspaces, err
+		}
+		workspace.Owner = owner
 	}
 
 	// fetch manual info per workspace
 	for _, workspace := range workspaces {
-		pre, err := workspace.getManual(preFileName)
+		pre, err := workspace.getManual(src, preFileName)
 		if err != nil {
 			return workspaces, err
 		}
 		workspace.PreManual = pre
 
-		post, err := workspace.getManual(postFileName)
+		post, err := workspace.getManual(src, postFileName)
 		if err != nil {
 			return workspaces, err
 		}
@@ -115,6 +139,16 @@ func GetWorkspaces(root string, ignore []string) (map[string]*Workspace, error)
 		workspace.Inputs = append(workspace.Inputs, mi...)
 	}
 
+	// point inputs and outputs to the workspace they belong to
+	for _, workspace := range workspaces {
+		for i := range workspace.Inputs {
+			workspace.Inputs[i].BelongsTo = workspace
+		}
+		for i := range workspace.Outputs {
+			workspace.Outputs[i].BelongsTo = workspace
+		}
+	}
+
 	// get relations between workspace inputs and outputs
 	for _, workspace := range workspaces {
 		for i, input := range workspace.Inputs {
@@ -134,6 +168,26 @@ func GetWorkspaces(root string, ignore []string) (map[string]*Workspace, error)
 	return workspaces, err
 }
 
+// FindWorkspace returns the single workspace whose root equals or contains
+// name.
+func FindWorkspace(workspaces map[string]*Workspace, name string) (*Workspace, error) {
+	candidates := []string{}
+	for root, ws := range workspaces {
+		if strings.TrimSuffix(root, "/") == strings.TrimSuffix(name, "/") {
+			return ws, nil
+		}
+		if strings.Contains(root, name) {
+			candidates = append(candidates, root)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("Workspace '%s' does not exist", name)
+	} else if len(candidates) > 1 {
+		return nil, fmt.Errorf("Workspace '%s' is ambiguous, it matches '%s'", name, strings.Join(candidates, "', '"))
+	}
+	return workspaces[candidates[0]], nil
+}
+
 type Workspace struct {
 	Files              map[string]*File `json:"-"`
 	Root               string           `json:"root"`
@@ -141,11 +195,14 @@ type Workspace struct {
 	Dependencies       []RemoteState    `json:"dependencies"`
 	Inputs             []Input          `json:"inputs"`
 	Outputs            []Output         `json:"outputs"`
+	Modules            []string         `json:"modules"`
+	Owner              string           `json:"owner,omitempty"`
 	PreManual          Manual           `json:"pre_manual"`
+	PreManualMarkdown  string           `json:"pre_manual_markdown"`
 	PreManualRendered  string           `json:"pre_manual_rendered"`
 	PostManual         Manual           `json:"post_manual"`
+	PostManualMarkdown string           `json:"post_manual_markdown"`
 	PostManualRendered string           `json:"post_manual_rendered"`
-	graphElement       *dot.Graph
 }
 
 type Manual string
@@ -155,27 +212,26 @@ type File struct {
 }
 
 type Input struct {
-	Name         string       `json:"name"`
-	FullName     string       `json:"full_name"`
-	Dependency   *RemoteState `json:"dependency"`
-	ReferesTo    *Output      `json:"referes_to"`
-	InFile       []string     `json:"in_file"`
-	BelongsTo    *Workspace   `json:"-"`
-	graphElement dot.Node
+	Name       string       `json:"name"`
+	FullName   string       `json:"full_name"`
+	Dependency *RemoteState `json:"dependency"`
+	ReferesTo  *Output      `json:"referes_to"`
+	InFile     []string     `json:"in_file"`
+	BelongsTo  *Workspace   `json:"-"`
 }
 
 type Output struct {
-	Name         string      `json:"name"`
-	Value        interface{} `json:"-"`
-	InFile       string      `json:"in_file"`
-	ReferedBy    []*Input    `json:"-"`
-	BelongsTo    *Workspace  `json:"-"`
-	graphElement dot.Node
+	Name      string      `json:"name"`
+	Value     interface{} `json:"-"`
+	InFile    string      `json:"in_file"`
+	Sensitive bool        `json:"sensitive,omitempty"`
+	ReferedBy []*Input    `json:"-"`
+	BelongsTo *Workspace  `json:"-"`
 }
 
-func (ws *Workspace) readFiles(basepath string) error {
+func (ws *Workspace) readFiles(src fileSource, basepath string) error {
 	for filename, file := range ws.Files {
-		raw, err := ioutil.ReadFile(basepath + filename)
+		raw, err := src.readFile(basepath + filename)
 		if err != nil {
 			return err
 		}
@@ -365,113 +421,74 @@ func (ws Workspace) getTerraformDependencies() ([]RemoteState, error) {
 	return d, nil
 }
 
+// getManualDependencies returns the remote states of the workspaces referenced
+// in the manuals. References that can not be resolved to an output are
+// skipped, they are reported by the linter. Ambiguous references depend on all
+// matching workspaces. References to the workspace itself, e.g. to its own
+// outputs in a PostManual, are no dependency.
 func (ws Workspace) getManualDependencies(workspaces map[string]*Workspace) ([]RemoteState, error) {
 	d := []RemoteState{}
-	manuals := map[string]Manual{
-		preFileName:  ws.PreManual,
-		postFileName: ws.PostManual,
-	}
-	re := regexp.MustCompile(`\{\{.*\}\}`)
-	for filename, m := range manuals {
-		submaches := re.FindAllStringSubmatch(string(m), -1)
-		for _, sm := range submaches {
-			match := sm[0]
-			seg := strings.SplitN(strings.Trim(match, "{}"), ".", 2)
-			if len(seg) != 2 {
-				return d, fmt.Errorf("reference '%s' seems to be malformed", match)
+	for _, ref := range ws.manualReferences() {
+		targets, _ := ref.targets(workspaces)
+		for _, workspace := range targets {
+			if workspace.Root == ws.Root {
+				continue
 			}
-
-			workspacePath := seg[0]
-
-			for name, workspace := range workspaces {
-				if strings.Contains(name, workspacePath) {
-
-					rs := RemoteState{
-						InFile:  filename,
-						Name:    workspacePath,
-						Bucket:  workspace.RemoteState.Bucket,
-						Key:     workspace.RemoteState.Key,
-						Profile: workspace.RemoteState.Profile,
-						Region:  workspace.RemoteState.Region,
-					}
-
-					d = append(d, rs)
-
-				}
+			rs := RemoteState{
+				InFile:  ref.InFile,
+				Name:    ref.Workspace,
+				Bucket:  workspace.RemoteState.Bucket,
+				Key:     workspace.RemoteState.Key,
+				Profile: workspace.RemoteState.Profile,
+				Region:  workspace.RemoteState.Region,
 			}
+			d = append(d, rs)
 		}
 	}
 	return d, nil
-
 }
 
+// getManualInputs returns an input per output referenced in the manuals.
+// References that can not be resolved are skipped, they are reported by the
+// linter, ambiguous references yield an input per matching workspace.
 func (ws Workspace) getManualInputs(workspaces map[string]*Workspace) ([]Input, error) {
 	inputs := []Input{}
-
-	manuals := map[string]Manual{
-		preFileName:  ws.PreManual,
-		postFileName: ws.PostManual,
-	}
-	re := regexp.MustCompile(`\{\{.*\}\}`)
-	for filename, m := range manuals {
-		submaches := re.FindAllSubmatch([]byte(m), -1)
-		for _, sm := range submaches {
-			match := string(sm[0])
-			seg := strings.SplitN(strings.Trim(match, "{}"), ".", 2)
-			if len(seg) != 2 {
-				return inputs, fmt.Errorf("reference '%s' seems to be malformed", match)
-			}
-
-			workspacePath := filepath.FromSlash(seg[0])
-			outputName := seg[1]
-
-			var o *Output
-			for name, workspace := range workspaces {
-				if strings.Contains(name, workspacePath) {
-					for _, output := range workspace.Outputs {
-						if output.Name == outputName {
-							o = &output
-						}
-					}
-				}
-			}
-			if o == nil {
-				return inputs, fmt.Errorf("reference to '%s' in workspace '%s' does not exist", outputName, workspacePath)
-			}
-
+	for _, ref := range ws.manualReferences() {
+		_, outputs := ref.targets(workspaces)
+		for _, o := range outputs {
 			input := Input{
-				Name:       outputName,
-				FullName:   match,
-				InFile:     []string{filename},
+				Name:       ref.Output,
+				FullName:   ref.Match,
+				InFile:     []string{ref.InFile},
 				Dependency: &o.BelongsTo.RemoteState,
 				BelongsTo:  &ws,
 			}
-
 			inputs = append(inputs, input)
 		}
 	}
-
 	return inputs, nil
 }
 
 func (ws Workspace) getOutputs() ([]Output, error) {
 	o := []Output{}
 	refs := map[string]*regexp.Regexp{
-		"output": regexp.MustCompile(`output\s*\"(?P<val>[a-zA-Z0-9_-]*)\"\s*\{`),
+		"output":    regexp.MustCompile(`output\s*\"(?P<val>[a-zA-Z0-9_-]*)\"\s*\{`),
+		"sensitive": regexp.MustCompile(`(?m)^\s*sensitive\s*=\s*true\b`),
 	}
 	for filename, file := range ws.Files {
-		outputMatches := refs["output"].FindAllSubmatch(file.Raw, -1)
+		outputMatches := refs["output"].FindAllSubmatchIndex(file.Raw, -1)
 		if len(outputMatches) < 1 {
 			continue
 		}
 
 		for _, m := range outputMatches {
-			if len(m) < 2 {
+			if len(m) < 4 {
 				continue
 			}
 			output := Output{
-				Name:      string(m[1]),
+				Name:      string(file.Raw[m[2]:m[3]]),
 				InFile:    filename,
+				Sensitive: refs["sensitive"].Match(blockBody(file.Raw, m[1])),
 				BelongsTo: &ws,
 			}
 			o = append(o, output)
@@ -481,11 +498,58 @@ func (ws Workspace) getOutputs() ([]Output, error) {
 	return o, nil
 }
 
-func (ws Workspace) getManual(filename string) (Manual, error) {
+// blockBody returns the content of the block opened by the brace preceding
+// start up to its closing brace.
+func blockBody(raw []byte, start int) []byte {
+	depth := 1
+	for i := start; i < len(raw); i++ {
+		switch raw[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return raw[start:i]
+			}
+		}
+	}
+	return raw[start:]
+}
+
+// getModuleSources returns the sources of all modules stored on the local file
+// system, relative to the workspace and sorted.
+func (ws Workspace) getModuleSources() ([]string, error) {
+	m := []string{}
+	refs := map[string]*regexp.Regexp{
+		"module": regexp.MustCompile(`module\s*\"[a-zA-Z0-9_-]*\"\s*\{[^\{\}]*?source\s*=\s*\"(?P<val>[^\"]*)\"`),
+	}
+	seen := map[string]bool{}
+	for _, file := range ws.Files {
+		moduleMatches := refs["module"].FindAllSubmatch(file.Raw, -1)
+		for _, match := range moduleMatches {
+			if len(match) < 2 {
+				continue
+			}
+			source := string(match[1])
+			if !strings.HasPrefix(source, "./") && !strings.HasPrefix(source, "../") {
+				continue
+			}
+			if !seen[source] {
+				seen[source] = true
+				m = append(m, source)
+			}
+		}
+	}
+	sort.Strings(m)
+	return m, nil
+}
+
+func (ws Workspace) getManual(src fileSource, filename string) (Manual, error) {
 	m := Manual("")
-	path := ws.Root + "/" + filename
-	if _, err := os.Stat(path); err == nil {
-		raw, err := ioutil.ReadFile(path)
+	// sources take paths of the local file system, gitFiles converts them
+	path := filepath.Join(ws.Root, filename)
+	if src.exists(path) {
+		raw, err := src.readFile(path)
 		if err != nil {
 			return m, err
 		}
@@ -498,7 +562,8 @@ func (m Manual) render(inputs []Input) (string, error) {
 	rendered := string(m)
 
 	for _, input := range inputs {
-		if strings.HasPrefix(input.FullName, "{{") &&
+		if input.ReferesTo != nil &&
+			strings.HasPrefix(input.FullName, "{{") &&
 			strings.HasSuffix(input.FullName, "}}") {
 			chdir := input.ReferesTo.BelongsTo.Root
 			command := "terraform"
@@ -523,3 +588,20 @@ func (m Manual) render(inputs []Input) (string, error) {
 
 	return rendered, nil
 }
+
+// getOwner returns the team owning the workspace as declared by a comment
+// such as '# solaris:owner platform' in one of its files.
+func (ws Workspace) getOwner() (string, error) {
+	ref := regexp.MustCompile(`(?m)^\s*(?:#|//)\s*solaris:owner\s+(?P<val>\S+)`)
+	owner, ownerFile := "", ""
+	for filename, file := range ws.Files {
+		for _, match := range ref.FindAllSubmatch(file.Raw, -1) {
+			o := string(match[1])
+			if owner != "" && owner != o {
+				return "", fmt.Errorf("conflicting owners '%s' (in %s) and '%s' (in %s) declared in workspace '%s'", owner, ownerFile, o, filename, ws.Root)
+			}
+			owner, ownerFile = o, filename
+		}
+	}
+	return owner, nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestGetWorkspacesManuals(t *testing.T) {
+	files := map[string]string{
+		"main.tf":           tfBackend("top"),
+		"PreManual.md":      "Prepare the account.\n",
+		"net/main.tf":       tfBackend("net"),
+		"net/PostManual.md": "Check the routes.\n",
+	}
+	dir, cleanup := gitFixture(t, files)
+	defer cleanup()
+	defer chdir(t, dir)()
+
+	tests := []struct {
+		source string
+		get    func() (map[string]*Workspace, error)
+	}{
+		{"local", func() (map[string]*Workspace, error) { return GetWorkspaces(".", []string{}) }},
+		{"git", func() (map[string]*Workspace, error) { return GetWorkspacesAt(".", []string{}, "HEAD") }},
+	}
+	for _, tt := range tests {
+		workspaces, err := tt.get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		top, net := workspaces[""], workspaces["net/"]
+		if top == nil || net == nil {
+			t.Fatalf("%s: workspaces are %q", tt.source, workspaceRoots(workspaceSlice(workspaces)))
+		}
+		if top.PreManual != "Prepare the account.\n" || top.PostManual != "" {
+			t.Errorf("%s: top-level manuals are '%s' and '%s'", tt.source, top.PreManual, top.PostManual)
+		}
+		if net.PreManual != "" || net.PostManual != "Check the routes.\n" {
+			t.Errorf("%s: manuals of 'net/' are '%s' and '%s'", tt.source, net.PreManual, net.PostManual)
+		}
+	}
+}